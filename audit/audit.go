@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"go-seckill/models"
+)
+
+type contextKey struct{}
+
+// NewContext 将待写入的审计日志挂到请求上下文，由审计中间件在请求结束后写入
+func NewContext(ctx context.Context, entry *models.AuditLog) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext 取出请求上下文中的审计日志，不在审计范围内的请求返回nil
+func FromContext(ctx context.Context) *models.AuditLog {
+	if ctx == nil {
+		return nil
+	}
+	entry, _ := ctx.Value(contextKey{}).(*models.AuditLog)
+	return entry
+}
+
+// Record 由服务层在变更完成后调用，记录操作、对象以及变更前后的快照
+// before 为nil表示新建，after 为nil表示删除；上下文中没有审计日志时不做任何事
+func Record(ctx context.Context, action, targetType string, targetID interface{}, before, after interface{}) {
+	entry := FromContext(ctx)
+	if entry == nil {
+		return
+	}
+
+	entry.Action = action
+	entry.TargetType = targetType
+	entry.TargetID = fmt.Sprint(targetID)
+	entry.Before = marshal(before)
+	entry.After = marshal(after)
+	entry.Diff = Diff(before, after)
+}
+
+func marshal(v interface{}) string {
+	if isNil(v) {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Diff 比较两个对象序列化为JSON后的顶层字段，返回发生变化的字段
+func Diff(before, after interface{}) string {
+	b := toMap(before)
+	a := toMap(after)
+
+	changes := make(map[string]map[string]interface{})
+	for key, old := range b {
+		if value, ok := a[key]; !ok || !reflect.DeepEqual(old, value) {
+			changes[key] = map[string]interface{}{"before": old, "after": a[key]}
+		}
+	}
+	for key, value := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = map[string]interface{}{"before": nil, "after": value}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	if isNil(v) {
+		return m
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return map[string]interface{}{"value": string(data)}
+	}
+	return m
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
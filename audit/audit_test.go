@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go-seckill/models"
+)
+
+func TestRecordFillsEntryWithDiff(t *testing.T) {
+	entry := &models.AuditLog{Actor: "alice"}
+	ctx := NewContext(context.Background(), entry)
+
+	before := &models.Order{OrderNo: "ORD001", Status: models.OrderStatusPending}
+	after := *before
+	after.Status = models.OrderStatusCancelled
+	Record(ctx, "order.update_status", "order", "ORD001", before, &after)
+
+	if entry.Action != "order.update_status" || entry.TargetType != "order" || entry.TargetID != "ORD001" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(entry.Diff), &diff); err != nil {
+		t.Fatalf("diff %q: %v", entry.Diff, err)
+	}
+	if len(diff) != 1 || diff["status"]["before"] != "pending" || diff["status"]["after"] != "cancelled" {
+		t.Errorf("diff = %v; want only the status change", diff)
+	}
+}
+
+func TestRecordCreateAndDelete(t *testing.T) {
+	entry := &models.AuditLog{}
+	ctx := NewContext(context.Background(), entry)
+
+	var missing *models.Product
+	Record(ctx, "product.create", "product", 7, missing, &models.Product{Name: "phone"})
+	if entry.Before != "" || entry.After == "" || entry.TargetID != "7" {
+		t.Errorf("create entry = %+v; want empty before and a snapshot after", entry)
+	}
+
+	Record(ctx, "acl.remove", "access_rule", 3, &models.AccessRule{Value: "u1"}, nil)
+	if entry.Before == "" || entry.After != "" {
+		t.Errorf("delete entry = %+v; want a snapshot before and empty after", entry)
+	}
+}
+
+func TestRecordWithoutEntryIsNoop(t *testing.T) {
+	Record(context.Background(), "product.create", "product", 1, nil, &models.Product{})
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+)
+
+// maxBloomBits Redis字符串最大512MB，位图偏移量不能超过2^32-1，最后一位留作构建完成标记
+const maxBloomBits = 1<<32 - 1
+
+// bloomBatchSize 每次脚本调用写入的元素数，避免重建时单个脚本阻塞Redis过久
+const bloomBatchSize = 500
+
+var bloomAddScript = RegisterScript("bloom.add", `
+	for i = 1, #ARGV do
+		redis.call('setbit', KEYS[1], ARGV[i], 1)
+	end
+	return 1
+`, func(tx *MemoryTx, keys, args []string) (interface{}, error) {
+	for _, arg := range args {
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.SetBit(keys[0], offset, 1); err != nil {
+			return nil, err
+		}
+	}
+	return 1, nil
+})
+
+// bloomExistsScript ARGV[1]为构建完成标记位，标记位为0说明位图未完整构建（如键被淘汰后只写入了新元素），返回-1
+var bloomExistsScript = RegisterScript("bloom.exists", `
+	if redis.call('getbit', KEYS[1], ARGV[1]) == 0 then
+		return -1
+	end
+	for i = 2, #ARGV do
+		if redis.call('getbit', KEYS[1], ARGV[i]) == 0 then
+			return 0
+		end
+	end
+	return 1
+`, func(tx *MemoryTx, keys, args []string) (interface{}, error) {
+	for i, arg := range args {
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		bit, err := tx.GetBit(keys[0], offset)
+		if err != nil {
+			return nil, err
+		}
+		if bit == 0 {
+			if i == 0 {
+				return -1, nil
+			}
+			return 0, nil
+		}
+	}
+	return 1, nil
+})
+
+// ErrBloomNotBuilt 位图缺少构建完成标记，查询结果不可信
+var ErrBloomNotBuilt = errors.New("bloom filter not built")
+
+// BloomFilter 保存在Redis位图中的布隆过滤器，多个实例共享同一个位图
+// 只支持添加，判断不存在的元素一定不存在，判断存在的元素有一定概率误判
+type BloomFilter struct {
+	key    string
+	bits   uint64
+	hashes int
+}
+
+// NewBloomFilter 按预期元素数和误判率计算位图大小和哈希函数个数
+// 键名附带位图大小和哈希函数个数，调整容量或误判率后写入新的位图，不会与旧参数写入的位混用
+// capacity不大于0时按1处理，falsePositiveRate不在(0, 1)区间时按0.01处理
+func NewBloomFilter(prefix string, capacity int, falsePositiveRate float64) *BloomFilter {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	n := float64(capacity)
+	bits := math.Ceil(-n * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	if bits > maxBloomBits {
+		bits = maxBloomBits
+	}
+	hashes := int(math.Round(bits / n * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	f := &BloomFilter{bits: uint64(bits), hashes: hashes}
+	f.key = fmt.Sprintf("%s:%d:%d", prefix, f.bits, f.hashes)
+	return f
+}
+
+// Key 返回位图的键名
+func (f *BloomFilter) Key() string {
+	return f.key
+}
+
+// Bits 返回位图大小
+func (f *BloomFilter) Bits() uint64 {
+	return f.bits
+}
+
+// Hashes 返回哈希函数个数
+func (f *BloomFilter) Hashes() int {
+	return f.hashes
+}
+
+// Add 添加元素，元素较多时分批写入
+func (f *BloomFilter) Add(items ...string) error {
+	for start := 0; start < len(items); start += bloomBatchSize {
+		end := start + bloomBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		offsets := make([]interface{}, 0, (end-start)*f.hashes)
+		for _, item := range items[start:end] {
+			offsets = append(offsets, f.offsets(item)...)
+		}
+		if err := bloomAddScript.Run([]string{f.key}, offsets...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkBuilt 设置构建完成标记，全部元素写入后调用
+func (f *BloomFilter) MarkBuilt() error {
+	return bloomAddScript.Run([]string{f.key}, f.bits).Err()
+}
+
+// Exists 判断元素是否可能存在，位图未构建完成（键丢失或未调用MarkBuilt）时返回ErrBloomNotBuilt
+func (f *BloomFilter) Exists(item string) (bool, error) {
+	var reply struct{ Exists int64 }
+	args := append([]interface{}{f.bits}, f.offsets(item)...)
+	if err := bloomExistsScript.Run([]string{f.key}, args...).Scan(&reply); err != nil {
+		return false, err
+	}
+	if reply.Exists < 0 {
+		return false, ErrBloomNotBuilt
+	}
+	return reply.Exists == 1, nil
+}
+
+// offsets 用双重哈希 h1 + i*h2 生成元素对应的各个位
+func (f *BloomFilter) offsets(item string) []interface{} {
+	h := fnv.New64a()
+	h.Write([]byte(item))
+	h1 := h.Sum64()
+	h2 := mix64(h1) | 1
+
+	offsets := make([]interface{}, f.hashes)
+	for i := range offsets {
+		offsets[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return offsets
+}
+
+// mix64 splitmix64的终结函数，由h1派生出相互独立的第二个哈希值
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBloomFilterParameters(t *testing.T) {
+	f := NewBloomFilter("bloom", 1000, 0.01)
+	if f.Bits() != 9586 || f.Hashes() != 7 {
+		t.Errorf("bits = %d, hashes = %d; want 9586, 7", f.Bits(), f.Hashes())
+	}
+	if f.Key() != "bloom:9586:7" {
+		t.Errorf("key = %q", f.Key())
+	}
+
+	// 参数无效时使用默认值
+	if f := NewBloomFilter("bloom", 0, 2); f.Bits() != 10 || f.Hashes() != 7 {
+		t.Errorf("invalid params: bits = %d, hashes = %d", f.Bits(), f.Hashes())
+	}
+}
+
+func TestBloomFilterMembership(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		SetStore(s)
+		f := NewBloomFilter("bloom", 1000, 0.01)
+
+		items := make([]string, 1000)
+		for i := range items {
+			items[i] = strconv.Itoa(i + 1)
+		}
+		if err := f.Add(items...); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if err := f.MarkBuilt(); err != nil {
+			t.Fatalf("MarkBuilt: %v", err)
+		}
+		for _, item := range items {
+			if ok, err := f.Exists(item); err != nil || !ok {
+				t.Fatalf("Exists(%s) = %v, %v; added items must never be rejected", item, ok, err)
+			}
+		}
+
+		falsePositives := 0
+		for i := 0; i < 5000; i++ {
+			if ok, _ := f.Exists("missing-" + strconv.Itoa(i)); ok {
+				falsePositives++
+			}
+		}
+		if rate := float64(falsePositives) / 5000; rate > 0.02 {
+			t.Errorf("false positive rate = %.4f; want about 0.01", rate)
+		}
+	})
+}
+
+func TestBloomFilterReportsMissingBitmap(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		SetStore(s)
+		f := NewBloomFilter("bloom", 1000, 0.01)
+
+		// 只写入元素未标记构建完成，与键被淘汰后又写入新元素的情况相同
+		if err := f.Add("1"); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if _, err := f.Exists("1"); !errors.Is(err, ErrBloomNotBuilt) {
+			t.Errorf("Exists before MarkBuilt err = %v; want ErrBloomNotBuilt", err)
+		}
+
+		if err := f.MarkBuilt(); err != nil {
+			t.Fatalf("MarkBuilt: %v", err)
+		}
+		if ok, err := f.Exists("1"); err != nil || !ok {
+			t.Errorf("Exists after MarkBuilt = %v, %v", ok, err)
+		}
+
+		s.Del(f.Key())
+		if _, err := f.Exists("1"); !errors.Is(err, ErrBloomNotBuilt) {
+			t.Errorf("Exists after key loss err = %v; want ErrBloomNotBuilt", err)
+		}
+	})
+}
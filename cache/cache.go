@@ -11,73 +11,188 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
-var RDB *redis.Client
 var ctx = context.Background()
 
+// Redis 部署模式
+const (
+	ModeSingle   = "single"
+	ModeSentinel = "sentinel"
+	ModeCluster  = "cluster"
+)
+
 func InitRedis(cfg *config.Config) error {
-	RDB = redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Addr,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-		PoolSize: cfg.Redis.PoolSize,
-	})
-
-	_, err := RDB.Ping(ctx).Result()
+	client, err := NewClient(cfg.Redis)
 	if err != nil {
+		return err
+	}
+	SetStore(NewRedisStore(client))
+
+	if err := store.Ping(); err != nil {
 		return fmt.Errorf("failed to connect redis: %w", err)
 	}
 
-	log.Println("Redis connected successfully")
-	return nil
+	log.Printf("Redis connected successfully (%s)", cfg.Redis.Mode)
+	return LoadScripts()
+}
+
+// NewClient 按部署模式创建Redis客户端
+func NewClient(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	addrs := cfg.Addrs
+	if len(addrs) == 0 {
+		addrs = []string{cfg.Addr}
+	}
+
+	switch cfg.Mode {
+	case ModeSingle, "":
+		return redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
+			PoolSize: cfg.PoolSize,
+		}), nil
+	case ModeSentinel:
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires a master name")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.MasterName,
+			SentinelAddrs:    addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Password:         cfg.Password,
+			DB:               cfg.DB,
+			PoolSize:         cfg.PoolSize,
+		}), nil
+	case ModeCluster:
+		// 集群模式只有0号库，忽略DB配置
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    addrs,
+			Password: cfg.Password,
+			PoolSize: cfg.PoolSize,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported redis mode %q, expected single|sentinel|cluster", cfg.Mode)
+	}
 }
 
 // Get 获取缓存
 func Get(key string) (string, error) {
-	return RDB.Get(ctx, key).Result()
+	return store.Get(key)
+}
+
+// MGet 批量获取缓存，一次往返读取全部键，不存在的键对应nil，键可以分布在不同的槽
+func MGet(keys ...string) ([]interface{}, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return store.MGet(keys...)
+}
+
+// Move 将键移动到新键名，保留剩余过期时间，两个键可以在不同的槽
+func Move(src, dst string) (bool, error) {
+	return store.Move(src, dst)
 }
 
 // Set 设置缓存
 func Set(key string, value interface{}, expiration time.Duration) error {
-	return RDB.Set(ctx, key, value, expiration).Err()
+	return store.Set(key, value, expiration)
 }
 
 // Del 删除缓存
 func Del(key string) error {
-	return RDB.Del(ctx, key).Err()
+	return store.Del(key)
 }
 
 // Incr 递增
 func Incr(key string) (int64, error) {
-	return RDB.Incr(ctx, key).Result()
+	return store.Incr(key)
+}
+
+// IncrBy 按指定步长递增
+func IncrBy(key string, value int64) (int64, error) {
+	return store.IncrBy(key, value)
 }
 
 // Decr 递减
 func Decr(key string) (int64, error) {
-	return RDB.Decr(ctx, key).Result()
+	return store.Decr(key)
 }
 
 // HGet 获取哈希字段
 func HGet(key, field string) (string, error) {
-	return RDB.HGet(ctx, key, field).Result()
+	return store.HGet(key, field)
 }
 
 // HSet 设置哈希字段
 func HSet(key, field string, value interface{}) error {
-	return RDB.HSet(ctx, key, field, value).Err()
+	return store.HSet(key, field, value)
+}
+
+// HSetWithExpire 在一个事务中整体替换哈希并设置过期时间
+func HSetWithExpire(key string, values map[string]interface{}, expiration time.Duration) error {
+	return store.HSetWithExpire(key, values, expiration)
+}
+
+// HGetAll 获取哈希全部字段
+func HGetAll(key string) (map[string]string, error) {
+	return store.HGetAll(key)
+}
+
+// HDel 删除哈希字段
+func HDel(key string, fields ...string) error {
+	return store.HDel(key, fields...)
 }
 
 // HIncrBy 哈希字段递增
 func HIncrBy(key, field string, incr int64) (int64, error) {
-	return RDB.HIncrBy(ctx, key, field, incr).Result()
+	return store.HIncrBy(key, field, incr)
 }
 
 // SetNX 设置键，仅当键不存在时
 func SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
-	return RDB.SetNX(ctx, key, value, expiration).Result()
+	return store.SetNX(key, value, expiration)
+}
+
+// Expire 设置过期时间
+func Expire(key string, expiration time.Duration) error {
+	return store.Expire(key, expiration)
+}
+
+// TTL 获取剩余过期时间
+func TTL(key string) (time.Duration, error) {
+	return store.TTL(key)
+}
+
+// SAdd 添加集合成员
+func SAdd(key string, members ...interface{}) error {
+	return store.SAdd(key, members...)
 }
 
-// Eval Lua脚本执行
-func Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
-	return RDB.Eval(ctx, script, keys, args...).Result()
+// SIsMember 判断是否为集合成员
+func SIsMember(key string, member interface{}) (bool, error) {
+	return store.SIsMember(key, member)
 }
 
+// SCard 获取集合成员数
+func SCard(key string) (int64, error) {
+	return store.SCard(key)
+}
+
+// Publish 发布消息
+func Publish(channel string, message interface{}) error {
+	return store.Publish(channel, message)
+}
+
+// Subscribe 订阅频道
+func Subscribe(channels ...string) Subscription {
+	return store.Subscribe(channels...)
+}
+
+// XAdd 向Stream追加消息，返回消息ID
+func XAdd(stream string, values map[string]interface{}) (string, error) {
+	return store.XAdd(stream, values)
+}
+
+// XRevRangeN 按ID倒序读取Stream中[stop, start]区间的最多count条消息
+func XRevRangeN(stream, start, stop string, count int64) ([]redis.XMessage, error) {
+	return store.XRevRangeN(stream, start, stop, count)
+}
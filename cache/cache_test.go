@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"testing"
+
+	"go-seckill/config"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestSlot(t *testing.T) {
+	// 期望值取自 CLUSTER KEYSLOT
+	for key, want := range map[string]int{
+		"123456789":             12739,
+		"foo":                   12182,
+		"{user1000}.following":  3443,
+		"{user1000}.followers":  3443,
+		"foo{}{bar}":            8363,
+		"seckill:{inventory}:1": Slot("inventory"),
+	} {
+		if got := Slot(key); got != want {
+			t.Errorf("Slot(%q) = %d; want %d", key, got, want)
+		}
+	}
+}
+
+// newTestRedisClient 启动miniredis并将包级存储切换为连接它的RedisStore
+func newTestRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	SetStore(NewRedisStore(client))
+	return client
+}
+
+func TestNewClientModes(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	single, err := NewClient(config.RedisConfig{Mode: ModeSingle, Addr: mr.Addr()})
+	if err != nil {
+		t.Fatalf("single: %v", err)
+	}
+	defer single.Close()
+	if _, ok := single.(*redis.Client); !ok {
+		t.Errorf("single mode client = %T; want *redis.Client", single)
+	}
+
+	sentinel, err := NewClient(config.RedisConfig{Mode: ModeSentinel, Addrs: []string{mr.Addr()}, MasterName: "mymaster"})
+	if err != nil {
+		t.Fatalf("sentinel: %v", err)
+	}
+	sentinel.Close()
+	if _, err := NewClient(config.RedisConfig{Mode: ModeSentinel, Addrs: []string{mr.Addr()}}); err == nil {
+		t.Error("sentinel mode without master name should fail")
+	}
+	if _, err := NewClient(config.RedisConfig{Mode: "proxy", Addr: mr.Addr()}); err == nil {
+		t.Error("unknown mode should fail")
+	}
+
+	// miniredis 以单节点集群响应 CLUSTER SLOTS，可作为集群客户端的本地替身
+	cluster, err := NewClient(config.RedisConfig{Mode: ModeCluster, Addrs: []string{mr.Addr()}})
+	if err != nil {
+		t.Fatalf("cluster: %v", err)
+	}
+	defer cluster.Close()
+	if _, ok := cluster.(*redis.ClusterClient); !ok {
+		t.Fatalf("cluster mode client = %T; want *redis.ClusterClient", cluster)
+	}
+
+	SetStore(NewRedisStore(cluster))
+	if err := Set("k", "v", 0); err != nil {
+		t.Fatalf("cluster Set: %v", err)
+	}
+	if v, err := Get("k"); err != nil || v != "v" {
+		t.Errorf("cluster Get = %q, %v", v, err)
+	}
+	var reply struct {
+		OK    bool
+		Count int64
+	}
+	if err := testIncrScript.Run([]string{"{p}:a"}, 1, "x").Scan(&reply); err != nil || reply.Count != 1 {
+		t.Errorf("cluster script = %+v, %v", reply, err)
+	}
+}
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LocalCache 进程内LRU缓存，位于Redis之前以减少热点数据的网络往返
+// 条目数超过上限时淘汰最久未访问的条目，超过TTL的条目视为未命中。
+// 多实例部署时通过Redis发布订阅广播失效的键，订阅断开期间丢失的通知由TTL兜底。
+type LocalCache struct {
+	maxEntries int
+	ttl        time.Duration
+	channel    string
+	now        func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	// generation 每次删除条目时加一，读取前记录，回填时不一致说明期间发生过失效
+	generation uint64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type localEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time
+}
+
+// LocalCacheStats 本地缓存命中统计
+type LocalCacheStats struct {
+	Entries   int     `json:"entries"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// NewLocalCache 创建本地缓存，channel为空时只在本实例内失效
+func NewLocalCache(maxEntries int, ttl time.Duration, channel string) *LocalCache {
+	return &LocalCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		channel:    channel,
+		now:        time.Now,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Get 获取未过期的条目
+func (c *LocalCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	entry := elem.Value.(*localEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.removeElement(elem)
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	c.hits.Add(1)
+	return entry.value, true
+}
+
+// Set 写入条目，超过容量时淘汰最久未访问的条目
+func (c *LocalCache) Set(key string, value interface{}) {
+	if c.maxEntries <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value)
+}
+
+// set 写入条目，调用方需持有锁
+func (c *LocalCache) set(key string, value interface{}) {
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&localEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Generation 返回当前的失效代数，与SetIfGeneration配合使用
+func (c *LocalCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// SetIfGeneration 自记录generation以来没有任何条目被删除时才写入，
+// 避免失效前读到的旧值在失效后写回本地缓存，返回是否写入
+func (c *LocalCache) SetIfGeneration(key string, value interface{}, generation uint64) bool {
+	if c.maxEntries <= 0 || c.ttl <= 0 {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return false
+	}
+	c.set(key, value)
+	return true
+}
+
+// Delete 删除本实例的条目
+func (c *LocalCache) Delete(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// Invalidate 删除本实例的条目并通知其他实例删除
+func (c *LocalCache) Invalidate(key string) error {
+	c.Delete(key)
+	if c.channel == "" {
+		return nil
+	}
+	return Publish(c.channel, key)
+}
+
+func (c *LocalCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*localEntry).key)
+}
+
+// Stats 返回当前条目数和累计命中统计
+func (c *LocalCache) Stats() LocalCacheStats {
+	c.mu.Lock()
+	entries := c.ll.Len()
+	c.mu.Unlock()
+
+	stats := LocalCacheStats{
+		Entries:   entries,
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// Start 订阅失效通知，收到的键从本实例删除，ctx结束后停止
+func (c *LocalCache) Start(ctx context.Context) {
+	if c.channel == "" {
+		return
+	}
+
+	pubsub := Subscribe(c.channel)
+	// 等待订阅生效，避免启动后立即发生的失效通知丢失
+	if err := pubsub.Wait(ctx); err != nil {
+		log.Printf("Failed to subscribe to %s: %v", c.channel, err)
+	}
+	go func() {
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				c.Delete(msg.Payload)
+			}
+		}
+	}()
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocalCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLocalCache(2, time.Minute, "")
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted as least recently used")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("a = %v, %v; want 1", v, ok)
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("c = %v, %v; want 3", v, ok)
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Hits != 3 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestLocalCacheExpiresEntries(t *testing.T) {
+	now := time.Now()
+	c := NewLocalCache(10, time.Second, "")
+	c.now = func() time.Time { return now }
+	c.Set("a", 1)
+
+	now = now.Add(999 * time.Millisecond)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("entry expired before ttl")
+	}
+	now = now.Add(time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("entry still present after ttl")
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Errorf("expired entry not removed, stats = %+v", stats)
+	}
+}
+
+func TestLocalCacheSkipsSetAfterInvalidation(t *testing.T) {
+	c := NewLocalCache(10, time.Minute, "")
+	// 失效前读到的旧值不能在失效后写回
+	generation := c.Generation()
+	c.Delete("a")
+	if c.SetIfGeneration("a", "stale", generation) {
+		t.Error("SetIfGeneration wrote a value read before the invalidation")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("stale value cached")
+	}
+	if !c.SetIfGeneration("a", "fresh", c.Generation()) {
+		t.Error("SetIfGeneration rejected a value read after the invalidation")
+	}
+}
+
+func TestLocalCacheInvalidatesAcrossInstances(t *testing.T) {
+	SetStore(NewMemoryStore())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	local := NewLocalCache(10, time.Minute, "test:invalidate")
+	remote := NewLocalCache(10, time.Minute, "test:invalidate")
+	remote.Start(ctx)
+	local.Set("product:1", "v1")
+	remote.Set("product:1", "v1")
+
+	if err := local.Invalidate("product:1"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	if _, ok := local.Get("product:1"); ok {
+		t.Error("local entry not removed")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := remote.Get("product:1"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("remote entry not invalidated via pub/sub")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,804 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+	errStreamID   = errors.New("ERR Invalid stream ID specified as stream command argument")
+)
+
+const (
+	kindString = iota + 1
+	kindHash
+	kindSet
+	kindStream
+)
+
+type memoryEntry struct {
+	kind     int
+	str      string
+	hash     map[string]string
+	set      map[string]struct{}
+	stream   []redis.XMessage
+	lastID   streamID
+	expireAt time.Time
+}
+
+// MemoryStore 纯内存存储，语义与Redis一致，脚本通过注册时提供的Go实现执行
+// 所有操作串行执行，脚本执行期间持有锁，因此与Lua脚本一样是原子的
+type MemoryStore struct {
+	mu     sync.Mutex
+	data   map[string]*memoryEntry
+	subs   map[string][]*memorySubscription
+	offset time.Duration
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data: make(map[string]*memoryEntry),
+		subs: make(map[string][]*memorySubscription),
+	}
+}
+
+// FastForward 将存储的时钟向前拨动，用于测试过期
+func (s *MemoryStore) FastForward(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset += d
+}
+
+func (s *MemoryStore) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// lookup 返回未过期的键，kind不为0时校验类型，调用方需持有锁
+func (s *MemoryStore) lookup(key string, kind int) (*memoryEntry, error) {
+	e, ok := s.data[key]
+	if !ok {
+		return nil, nil
+	}
+	if !e.expireAt.IsZero() && !s.now().Before(e.expireAt) {
+		delete(s.data, key)
+		return nil, nil
+	}
+	if kind != 0 && e.kind != kind {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// create 返回指定类型的键，不存在时创建
+func (s *MemoryStore) create(key string, kind int) (*memoryEntry, error) {
+	e, err := s.lookup(key, kind)
+	if err != nil || e != nil {
+		return e, err
+	}
+	e = &memoryEntry{kind: kind}
+	switch kind {
+	case kindHash:
+		e.hash = make(map[string]string)
+	case kindSet:
+		e.set = make(map[string]struct{})
+	}
+	s.data[key] = e
+	return e, nil
+}
+
+func (s *MemoryStore) get(key string) (string, error) {
+	e, err := s.lookup(key, kindString)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", Nil
+	}
+	return e.str, nil
+}
+
+func (s *MemoryStore) set(key, value string, expiration time.Duration) {
+	e := &memoryEntry{kind: kindString, str: value}
+	if expiration > 0 {
+		e.expireAt = s.now().Add(expiration)
+	}
+	s.data[key] = e
+}
+
+func (s *MemoryStore) del(keys ...string) int64 {
+	var n int64
+	for _, key := range keys {
+		if e, _ := s.lookup(key, 0); e != nil {
+			delete(s.data, key)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) incrBy(key string, value int64) (int64, error) {
+	e, err := s.lookup(key, kindString)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if e != nil {
+		if n, err = strconv.ParseInt(e.str, 10, 64); err != nil {
+			return 0, errNotInteger
+		}
+	} else {
+		e = &memoryEntry{kind: kindString}
+		s.data[key] = e
+	}
+	n += value
+	e.str = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (s *MemoryStore) expire(key string, expiration time.Duration) {
+	e, _ := s.lookup(key, 0)
+	if e == nil {
+		return
+	}
+	if expiration <= 0 {
+		delete(s.data, key)
+		return
+	}
+	e.expireAt = s.now().Add(expiration)
+}
+
+func (s *MemoryStore) ttl(key string) time.Duration {
+	e, _ := s.lookup(key, 0)
+	switch {
+	case e == nil:
+		return -2
+	case e.expireAt.IsZero():
+		return -1
+	}
+	// 与Redis的TTL命令一样四舍五入到秒
+	return (e.expireAt.Sub(s.now()) + time.Second/2).Truncate(time.Second)
+}
+
+func (s *MemoryStore) hset(key string, values map[string]interface{}) error {
+	e, err := s.create(key, kindHash)
+	if err != nil {
+		return err
+	}
+	for field, value := range values {
+		v, err := formatArg(value)
+		if err != nil {
+			return err
+		}
+		e.hash[field] = v
+	}
+	return nil
+}
+
+func (s *MemoryStore) xadd(stream string, values map[string]interface{}) (string, error) {
+	fields := make(map[string]interface{}, len(values))
+	for field, value := range values {
+		v, err := formatArg(value)
+		if err != nil {
+			return "", err
+		}
+		fields[field] = v
+	}
+
+	e, err := s.create(stream, kindStream)
+	if err != nil {
+		return "", err
+	}
+	id := streamID{ms: uint64(s.now().UnixNano() / int64(time.Millisecond))}
+	if id.ms <= e.lastID.ms {
+		id = streamID{ms: e.lastID.ms, seq: e.lastID.seq + 1}
+	}
+	e.lastID = id
+	e.stream = append(e.stream, redis.XMessage{ID: id.String(), Values: fields})
+	return id.String(), nil
+}
+
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+func (s *MemoryStore) Get(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(key)
+}
+
+func (s *MemoryStore) MGet(keys ...string) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		// 与MGET一样，非字符串类型的键也返回nil
+		if v, err := s.get(key); err == nil {
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+func (s *MemoryStore) Set(key string, value interface{}, expiration time.Duration) error {
+	v, err := formatArg(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, v, expiration)
+	return nil
+}
+
+func (s *MemoryStore) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	v, err := formatArg(value)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, _ := s.lookup(key, 0); e != nil {
+		return false, nil
+	}
+	s.set(key, v, expiration)
+	return true, nil
+}
+
+func (s *MemoryStore) Del(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.del(key)
+	return nil
+}
+
+func (s *MemoryStore) Move(src, dst string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, _ := s.lookup(src, 0)
+	if e == nil {
+		return false, nil
+	}
+	if target, _ := s.lookup(dst, 0); target != nil {
+		return false, fmt.Errorf("%w: %s", ErrKeyExists, dst)
+	}
+	if e.kind != kindString && e.kind != kindStream {
+		return false, fmt.Errorf("cache: cannot move key %s of this type", src)
+	}
+	s.data[dst] = e
+	delete(s.data, src)
+	return true, nil
+}
+
+func (s *MemoryStore) Incr(key string) (int64, error) {
+	return s.IncrBy(key, 1)
+}
+
+func (s *MemoryStore) IncrBy(key string, value int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.incrBy(key, value)
+}
+
+func (s *MemoryStore) Decr(key string) (int64, error) {
+	return s.IncrBy(key, -1)
+}
+
+func (s *MemoryStore) Expire(key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key, expiration)
+	return nil
+}
+
+func (s *MemoryStore) TTL(key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ttl(key), nil
+}
+
+func (s *MemoryStore) HGet(key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(key, kindHash)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "", Nil
+	}
+	v, ok := e.hash[field]
+	if !ok {
+		return "", Nil
+	}
+	return v, nil
+}
+
+func (s *MemoryStore) HSet(key, field string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hset(key, map[string]interface{}{field: value})
+}
+
+func (s *MemoryStore) HSetWithExpire(key string, values map[string]interface{}, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.del(key)
+	if err := s.hset(key, values); err != nil {
+		return err
+	}
+	s.expire(key, expiration)
+	return nil
+}
+
+func (s *MemoryStore) HGetAll(key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(key, kindHash)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	if e != nil {
+		for field, value := range e.hash {
+			fields[field] = value
+		}
+	}
+	return fields, nil
+}
+
+func (s *MemoryStore) HDel(key string, fields ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(key, kindHash)
+	if err != nil || e == nil {
+		return err
+	}
+	for _, field := range fields {
+		delete(e.hash, field)
+	}
+	if len(e.hash) == 0 {
+		delete(s.data, key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) HIncrBy(key, field string, incr int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hincrBy(key, field, incr)
+}
+
+func (s *MemoryStore) hincrBy(key, field string, incr int64) (int64, error) {
+	e, err := s.create(key, kindHash)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if v, ok := e.hash[field]; ok {
+		if n, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, errors.New("ERR hash value is not an integer")
+		}
+	}
+	n += incr
+	e.hash[field] = strconv.FormatInt(n, 10)
+	return n, nil
+}
+
+func (s *MemoryStore) SAdd(key string, members ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.create(key, kindSet)
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		m, err := formatArg(member)
+		if err != nil {
+			return err
+		}
+		e.set[m] = struct{}{}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SIsMember(key string, member interface{}) (bool, error) {
+	m, err := formatArg(member)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(key, kindSet)
+	if err != nil || e == nil {
+		return false, err
+	}
+	_, ok := e.set[m]
+	return ok, nil
+}
+
+func (s *MemoryStore) SCard(key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(key, kindSet)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	return int64(len(e.set)), nil
+}
+
+// Publish 向订阅者投递消息，订阅者缓冲区已满时丢弃该消息
+func (s *MemoryStore) Publish(channel string, message interface{}) error {
+	payload, err := formatArg(message)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.publish(channel, payload)
+	return nil
+}
+
+func (s *MemoryStore) publish(channel, payload string) int64 {
+	var n int64
+	for _, sub := range s.subs[channel] {
+		select {
+		case sub.ch <- &Message{Channel: channel, Payload: payload}:
+			n++
+		default:
+		}
+	}
+	return n
+}
+
+// Subscribe 订阅频道，订阅立即生效
+func (s *MemoryStore) Subscribe(channels ...string) Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub := &memorySubscription{store: s, channels: channels, ch: make(chan *Message, 100)}
+	for _, channel := range channels {
+		s.subs[channel] = append(s.subs[channel], sub)
+	}
+	return sub
+}
+
+func (s *MemoryStore) XAdd(stream string, values map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.xadd(stream, values)
+}
+
+func (s *MemoryStore) XRevRangeN(stream, start, stop string, count int64) ([]redis.XMessage, error) {
+	upper, err := parseStreamID(start, true)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := parseStreamID(stop, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookup(stream, kindStream)
+	if err != nil || e == nil {
+		return []redis.XMessage{}, err
+	}
+
+	messages := []redis.XMessage{}
+	for i := len(e.stream) - 1; i >= 0 && (count <= 0 || int64(len(messages)) < count); i-- {
+		id, _ := parseStreamID(e.stream[i].ID, false)
+		if id.less(lower) {
+			break
+		}
+		if upper.less(id) {
+			continue
+		}
+		values := make(map[string]interface{}, len(e.stream[i].Values))
+		for field, value := range e.stream[i].Values {
+			values[field] = value
+		}
+		messages = append(messages, redis.XMessage{ID: e.stream[i].ID, Values: values})
+	}
+	return messages, nil
+}
+
+// LoadScripts 内存实现无需预加载，只校验每个脚本都提供了Go实现
+func (s *MemoryStore) LoadScripts(scripts []*Script) error {
+	for _, script := range scripts {
+		if script.fn == nil {
+			return fmt.Errorf("script %s has no in-memory implementation", script.name)
+		}
+	}
+	return nil
+}
+
+// RunScript 在持有锁的情况下执行脚本的Go实现，参数按go-redis的规则转换为字符串后传入
+func (s *MemoryStore) RunScript(script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	if script.fn == nil {
+		return nil, fmt.Errorf("script %s has no in-memory implementation", script.name)
+	}
+	argv := make([]string, len(args))
+	for i, arg := range args {
+		v, err := formatArg(arg)
+		if err != nil {
+			return nil, err
+		}
+		argv[i] = v
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, err := script.fn(&MemoryTx{store: s}, keys, argv)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeScriptValue(val), nil
+}
+
+// normalizeScriptValue 按Lua到Redis的转换规则转换返回值：整数统一为int64，true为1，false为nil
+func normalizeScriptValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		return int64(v)
+	case bool:
+		if v {
+			return int64(1)
+		}
+		return nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalizeScriptValue(item)
+		}
+		return out
+	default:
+		return val
+	}
+}
+
+// MemoryTx 脚本的Go实现可用的命令，语义与脚本中的redis.call相同
+// 只在MemoryStore.RunScript持有锁期间有效
+type MemoryTx struct {
+	store *MemoryStore
+}
+
+// Get 对应GET，键不存在时返回Nil
+func (tx *MemoryTx) Get(key string) (string, error) {
+	return tx.store.get(key)
+}
+
+// GetInt 对应 tonumber(redis.call('get', key) or 0)，值不是整数时返回错误
+func (tx *MemoryTx) GetInt(key string) (int64, error) {
+	v, err := tx.store.get(key)
+	if errors.Is(err, Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not a number: %q", key, v)
+	}
+	return n, nil
+}
+
+// Set 对应SET，expiration为0时不过期
+func (tx *MemoryTx) Set(key string, value interface{}, expiration time.Duration) error {
+	v, err := formatArg(value)
+	if err != nil {
+		return err
+	}
+	tx.store.set(key, v, expiration)
+	return nil
+}
+
+// IncrBy 对应INCRBY
+func (tx *MemoryTx) IncrBy(key string, value int64) (int64, error) {
+	return tx.store.incrBy(key, value)
+}
+
+// Exists 对应EXISTS，判断单个键是否存在
+func (tx *MemoryTx) Exists(key string) bool {
+	e, _ := tx.store.lookup(key, 0)
+	return e != nil
+}
+
+// Type 对应TYPE，键不存在时返回none
+func (tx *MemoryTx) Type(key string) string {
+	e, _ := tx.store.lookup(key, 0)
+	if e == nil {
+		return "none"
+	}
+	switch e.kind {
+	case kindHash:
+		return "hash"
+	case kindSet:
+		return "set"
+	case kindStream:
+		return "stream"
+	default:
+		return "string"
+	}
+}
+
+// HExists 对应HEXISTS
+func (tx *MemoryTx) HExists(key, field string) (bool, error) {
+	e, err := tx.store.lookup(key, kindHash)
+	if err != nil || e == nil {
+		return false, err
+	}
+	_, ok := e.hash[field]
+	return ok, nil
+}
+
+// HSet 对应 HSET key field value ...
+func (tx *MemoryTx) HSet(key string, values map[string]interface{}) error {
+	return tx.store.hset(key, values)
+}
+
+// HIncrBy 对应HINCRBY
+func (tx *MemoryTx) HIncrBy(key, field string, incr int64) (int64, error) {
+	return tx.store.hincrBy(key, field, incr)
+}
+
+// Del 对应DEL，返回删除的键数
+func (tx *MemoryTx) Del(keys ...string) int64 {
+	return tx.store.del(keys...)
+}
+
+// GetBit 对应GETBIT，超出字符串长度的位为0
+func (tx *MemoryTx) GetBit(key string, offset int64) (int64, error) {
+	e, err := tx.store.lookup(key, kindString)
+	if err != nil || e == nil {
+		return 0, err
+	}
+	if offset>>3 >= int64(len(e.str)) {
+		return 0, nil
+	}
+	return int64(e.str[offset>>3]>>(7-offset&7)) & 1, nil
+}
+
+// SetBit 对应SETBIT，字符串长度不足时以0字节补齐，返回该位原来的值
+func (tx *MemoryTx) SetBit(key string, offset int64, value int) (int64, error) {
+	e, err := tx.store.create(key, kindString)
+	if err != nil {
+		return 0, err
+	}
+	buf := []byte(e.str)
+	if n := offset>>3 + 1; n > int64(len(buf)) {
+		buf = append(buf, make([]byte, n-int64(len(buf)))...)
+	}
+	mask := byte(1) << (7 - offset&7)
+	old := int64(0)
+	if buf[offset>>3]&mask != 0 {
+		old = 1
+	}
+	if value != 0 {
+		buf[offset>>3] |= mask
+	} else {
+		buf[offset>>3] &^= mask
+	}
+	e.str = string(buf)
+	return old, nil
+}
+
+// Expire 对应EXPIRE
+func (tx *MemoryTx) Expire(key string, expiration time.Duration) {
+	tx.store.expire(key, expiration)
+}
+
+// XAdd 对应 XADD key * field value ...
+func (tx *MemoryTx) XAdd(stream string, values map[string]interface{}) (string, error) {
+	return tx.store.xadd(stream, values)
+}
+
+// XAddMaxLen 对应 XADD key MAXLEN ~ maxLen * field value ...，内存存储按maxLen精确裁剪最早的消息
+func (tx *MemoryTx) XAddMaxLen(stream string, maxLen int64, values map[string]interface{}) (string, error) {
+	id, err := tx.store.xadd(stream, values)
+	if err != nil {
+		return "", err
+	}
+	if e, _ := tx.store.lookup(stream, kindStream); e != nil && int64(len(e.stream)) > maxLen {
+		e.stream = append([]redis.XMessage(nil), e.stream[int64(len(e.stream))-maxLen:]...)
+	}
+	return id, nil
+}
+
+// Publish 对应PUBLISH，返回收到消息的订阅者数
+func (tx *MemoryTx) Publish(channel, message string) int64 {
+	return tx.store.publish(channel, message)
+}
+
+// Time 对应TIME
+func (tx *MemoryTx) Time() time.Time {
+	return tx.store.now()
+}
+
+// memorySubscription 内存存储的订阅
+type memorySubscription struct {
+	store    *MemoryStore
+	channels []string
+	ch       chan *Message
+	closed   bool
+}
+
+func (sub *memorySubscription) Wait(ctx context.Context) error {
+	return nil
+}
+
+func (sub *memorySubscription) Channel() <-chan *Message {
+	return sub.ch
+}
+
+func (sub *memorySubscription) Close() error {
+	s := sub.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub.closed {
+		return nil
+	}
+	sub.closed = true
+	for _, channel := range sub.channels {
+		subs := s.subs[channel]
+		for i, other := range subs {
+			if other == sub {
+				s.subs[channel] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+	close(sub.ch)
+	return nil
+}
+
+// streamID Stream消息ID，格式为 毫秒时间戳-序号
+type streamID struct {
+	ms, seq uint64
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+func (id streamID) less(other streamID) bool {
+	return id.ms < other.ms || id.ms == other.ms && id.seq < other.seq
+}
+
+// parseStreamID 解析区间端点，省略序号时上界取最大序号、下界取0
+func parseStreamID(s string, upper bool) (streamID, error) {
+	switch s {
+	case "+":
+		return streamID{ms: ^uint64(0), seq: ^uint64(0)}, nil
+	case "-":
+		return streamID{}, nil
+	}
+
+	msPart, seqPart, hasSeq := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return streamID{}, errStreamID
+	}
+	id := streamID{ms: ms}
+	if !hasSeq {
+		if upper {
+			id.seq = ^uint64(0)
+		}
+		return id, nil
+	}
+	if id.seq, err = strconv.ParseUint(seqPart, 10, 64); err != nil {
+		return streamID{}, errStreamID
+	}
+	return id, nil
+}
@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore 基于Redis客户端的存储，支持单机、哨兵和集群客户端
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisStore 创建Redis存储
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Client 返回底层Redis客户端
+func (s *RedisStore) Client() redis.UniversalClient {
+	return s.client
+}
+
+func (s *RedisStore) Ping() error {
+	return s.client.Ping(ctx).Err()
+}
+
+func (s *RedisStore) Get(key string) (string, error) {
+	return s.client.Get(ctx, key).Result()
+}
+
+// MGet 用pipeline逐键GET，集群模式下按节点拆分后一次往返，键不要求在同一个槽
+func (s *RedisStore) MGet(keys ...string) ([]interface{}, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(keys))
+	for i, cmd := range cmds {
+		v, err := cmd.Result()
+		switch {
+		case err == nil:
+			values[i] = v
+		case errors.Is(err, redis.Nil) || strings.HasPrefix(err.Error(), "WRONGTYPE"):
+			// 与MGET一致，不存在或不是字符串的键返回nil
+		default:
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+func (s *RedisStore) Set(key string, value interface{}, expiration time.Duration) error {
+	return s.client.Set(ctx, key, value, expiration).Err()
+}
+
+func (s *RedisStore) SetNX(key string, value interface{}, expiration time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+func (s *RedisStore) Del(key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// moveBatch Move复制Stream时每批读取的消息数
+const moveBatch = 1000
+
+// Move 集群模式下RENAME不能跨槽，按类型复制值后删除源键
+func (s *RedisStore) Move(src, dst string) (bool, error) {
+	kind, err := s.client.Type(ctx, src).Result()
+	if err != nil || kind == "none" {
+		return false, err
+	}
+	n, err := s.client.Exists(ctx, dst).Result()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return false, fmt.Errorf("%w: %s", ErrKeyExists, dst)
+	}
+	ttl, err := s.client.PTTL(ctx, src).Result()
+	if err != nil {
+		return false, err
+	}
+
+	switch kind {
+	case "string":
+		v, err := s.client.Get(ctx, src).Result()
+		if err != nil {
+			return false, err
+		}
+		if err := s.client.Set(ctx, dst, v, 0).Err(); err != nil {
+			return false, err
+		}
+	case "stream":
+		// 保留消息ID，按ID分页时游标在迁移前后保持有效
+		for start := "-"; ; {
+			messages, err := s.client.XRangeN(ctx, src, start, "+", moveBatch).Result()
+			if err != nil {
+				return false, err
+			}
+			for _, msg := range messages {
+				if err := s.client.XAdd(ctx, &redis.XAddArgs{Stream: dst, ID: msg.ID, Values: msg.Values}).Err(); err != nil {
+					return false, err
+				}
+			}
+			if len(messages) < moveBatch {
+				break
+			}
+			start = "(" + messages[len(messages)-1].ID
+		}
+	default:
+		return false, fmt.Errorf("cache: cannot move %s key %s", kind, src)
+	}
+
+	if ttl > 0 {
+		if err := s.client.PExpire(ctx, dst, ttl).Err(); err != nil {
+			return false, err
+		}
+	}
+	return true, s.client.Del(ctx, src).Err()
+}
+
+func (s *RedisStore) Incr(key string) (int64, error) {
+	return s.client.Incr(ctx, key).Result()
+}
+
+func (s *RedisStore) IncrBy(key string, value int64) (int64, error) {
+	return s.client.IncrBy(ctx, key, value).Result()
+}
+
+func (s *RedisStore) Decr(key string) (int64, error) {
+	return s.client.Decr(ctx, key).Result()
+}
+
+func (s *RedisStore) Expire(key string, expiration time.Duration) error {
+	return s.client.Expire(ctx, key, expiration).Err()
+}
+
+func (s *RedisStore) TTL(key string) (time.Duration, error) {
+	return s.client.TTL(ctx, key).Result()
+}
+
+func (s *RedisStore) HGet(key, field string) (string, error) {
+	return s.client.HGet(ctx, key, field).Result()
+}
+
+func (s *RedisStore) HSet(key, field string, value interface{}) error {
+	return s.client.HSet(ctx, key, field, value).Err()
+}
+
+func (s *RedisStore) HSetWithExpire(key string, values map[string]interface{}, expiration time.Duration) error {
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.HSet(ctx, key, values)
+		pipe.Expire(ctx, key, expiration)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisStore) HGetAll(key string) (map[string]string, error) {
+	return s.client.HGetAll(ctx, key).Result()
+}
+
+func (s *RedisStore) HDel(key string, fields ...string) error {
+	return s.client.HDel(ctx, key, fields...).Err()
+}
+
+func (s *RedisStore) HIncrBy(key, field string, incr int64) (int64, error) {
+	return s.client.HIncrBy(ctx, key, field, incr).Result()
+}
+
+func (s *RedisStore) SAdd(key string, members ...interface{}) error {
+	return s.client.SAdd(ctx, key, members...).Err()
+}
+
+func (s *RedisStore) SIsMember(key string, member interface{}) (bool, error) {
+	return s.client.SIsMember(ctx, key, member).Result()
+}
+
+func (s *RedisStore) SCard(key string) (int64, error) {
+	return s.client.SCard(ctx, key).Result()
+}
+
+func (s *RedisStore) Publish(channel string, message interface{}) error {
+	return s.client.Publish(ctx, channel, message).Err()
+}
+
+func (s *RedisStore) Subscribe(channels ...string) Subscription {
+	return &redisSubscription{pubsub: s.client.Subscribe(ctx, channels...), done: make(chan struct{})}
+}
+
+func (s *RedisStore) XAdd(stream string, values map[string]interface{}) (string, error) {
+	return s.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+func (s *RedisStore) XRevRangeN(stream, start, stop string, count int64) ([]redis.XMessage, error) {
+	return s.client.XRevRangeN(ctx, stream, start, stop, count).Result()
+}
+
+// LoadScripts 通过SCRIPT LOAD预加载脚本，集群模式下加载到每个主节点
+func (s *RedisStore) LoadScripts(scripts []*Script) error {
+	load := func(ctx context.Context, c redis.Scripter) error {
+		for _, script := range scripts {
+			if err := script.script.Load(ctx, c).Err(); err != nil {
+				return fmt.Errorf("failed to load script %s: %w", script.name, err)
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return load(ctx, client)
+		})
+	} else {
+		err = load(ctx, s.client)
+	}
+	if err == nil {
+		log.Printf("Loaded %d Lua scripts", len(scripts))
+	}
+	return err
+}
+
+// RunScript 以EVALSHA执行脚本，Redis返回NOSCRIPT（重启、故障切换或SCRIPT FLUSH后）时退回EVAL，EVAL会重新缓存脚本
+func (s *RedisStore) RunScript(script *Script, keys []string, args ...interface{}) (interface{}, error) {
+	val, err := script.script.EvalSha(ctx, s.client, keys, args...).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		val, err = script.script.Eval(ctx, s.client, keys, args...).Result()
+	}
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return val, err
+}
+
+// redisSubscription 将go-redis的消息转换为Message
+type redisSubscription struct {
+	pubsub *redis.PubSub
+	once   sync.Once
+	ch     chan *Message
+	done   chan struct{}
+}
+
+func (s *redisSubscription) Wait(ctx context.Context) error {
+	_, err := s.pubsub.Receive(ctx)
+	return err
+}
+
+func (s *redisSubscription) Channel() <-chan *Message {
+	s.once.Do(func() {
+		s.ch = make(chan *Message, 100)
+		go func() {
+			defer close(s.ch)
+			for msg := range s.pubsub.Channel() {
+				select {
+				case s.ch <- &Message{Channel: msg.Channel, Payload: msg.Payload}:
+				case <-s.done:
+					return
+				}
+			}
+		}()
+	})
+	return s.ch
+}
+
+func (s *redisSubscription) Close() error {
+	close(s.done)
+	return s.pubsub.Close()
+}
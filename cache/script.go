@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Script 注册到脚本表的Lua脚本，通过EVALSHA调用，避免每次发送脚本源码
+type Script struct {
+	name   string
+	script *redis.Script
+	fn     ScriptFunc
+}
+
+// ScriptFunc 脚本的Go实现，供MemoryStore执行，keys、args对应Lua脚本的KEYS、ARGV
+// 返回值按Lua的返回约定构造：整数、字符串或由它们组成的[]interface{}
+type ScriptFunc func(tx *MemoryTx, keys []string, args []string) (interface{}, error)
+
+var (
+	scriptsMu sync.RWMutex
+	scripts   = make(map[string]*Script)
+)
+
+// RegisterScript 注册Lua脚本及其等价的Go实现，通常在包级变量中调用，InitRedis时统一预加载
+// 同名脚本重复注册会panic
+func RegisterScript(name, src string, fn ScriptFunc) *Script {
+	scriptsMu.Lock()
+	defer scriptsMu.Unlock()
+
+	if _, ok := scripts[name]; ok {
+		panic(fmt.Sprintf("cache: script %q registered twice", name))
+	}
+	s := &Script{name: name, script: redis.NewScript(src), fn: fn}
+	scripts[name] = s
+	return s
+}
+
+// LoadScripts 预加载全部已注册的脚本
+func LoadScripts() error {
+	scriptsMu.RLock()
+	list := make([]*Script, 0, len(scripts))
+	for _, s := range scripts {
+		list = append(list, s)
+	}
+	scriptsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+
+	return store.LoadScripts(list)
+}
+
+// Name 返回脚本名称
+func (s *Script) Name() string {
+	return s.name
+}
+
+// Run 原子执行脚本，脚本访问的键必须落在同一个槽
+func (s *Script) Run(keys []string, args ...interface{}) *ScriptResult {
+	if err := checkSameSlot(keys); err != nil {
+		return &ScriptResult{err: fmt.Errorf("script %s: %w", s.name, err)}
+	}
+
+	val, err := store.RunScript(s, keys, args...)
+	if err != nil {
+		err = fmt.Errorf("script %s: %w", s.name, err)
+	}
+	return &ScriptResult{val: val, err: err}
+}
+
+// ScriptResult 脚本执行结果
+type ScriptResult struct {
+	val interface{}
+	err error
+}
+
+// Err 返回执行错误
+func (r *ScriptResult) Err() error {
+	return r.err
+}
+
+// Scan 按位置将脚本返回的数组填充到dest指向的结构体，第i个导出字段对应数组第i个元素
+// 支持int/int64、string、bool（非零整数为true）、float64字段，数字字符串会按字段类型转换；
+// 返回值不是数组时视为单元素数组，元素少于字段数时其余字段保持零值
+func (r *ScriptResult) Scan(dest interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cache: Scan destination must be a pointer to struct, got %T", dest)
+	}
+	v = v.Elem()
+
+	values, ok := r.val.([]interface{})
+	if !ok {
+		values = []interface{}{r.val}
+	}
+
+	index := 0
+	for i := 0; i < v.NumField() && index < len(values); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if err := setScriptValue(v.Field(i), values[index]); err != nil {
+			return fmt.Errorf("cache: field %s: %w", v.Type().Field(i).Name, err)
+		}
+		index++
+	}
+	return nil
+}
+
+func setScriptValue(field reflect.Value, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			field.SetString(v)
+		case int64:
+			field.SetString(strconv.FormatInt(v, 10))
+		default:
+			return fmt.Errorf("cannot convert %T to string", value)
+		}
+	case reflect.Int, reflect.Int64, reflect.Bool, reflect.Float64:
+		var n int64
+		switch v := value.(type) {
+		case int64:
+			n = v
+		case string:
+			if field.Kind() == reflect.Float64 {
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return err
+				}
+				field.SetFloat(f)
+				return nil
+			}
+			parsed, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return err
+			}
+			n = parsed
+		default:
+			return fmt.Errorf("cannot convert %T to %s", value, field.Kind())
+		}
+		switch field.Kind() {
+		case reflect.Bool:
+			field.SetBool(n != 0)
+		case reflect.Float64:
+			field.SetFloat(float64(n))
+		default:
+			field.SetInt(n)
+		}
+	default:
+		return fmt.Errorf("unsupported field kind %s", field.Kind())
+	}
+	return nil
+}
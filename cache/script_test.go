@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+var testIncrScript = RegisterScript("test.incr", `
+	local n = redis.call('incrby', KEYS[1], ARGV[1])
+	return {1, n, ARGV[2], tostring(n)}
+`, func(tx *MemoryTx, keys, args []string) (interface{}, error) {
+	incr, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	n, err := tx.IncrBy(keys[0], incr)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{1, n, args[1], strconv.FormatInt(n, 10)}, nil
+})
+
+func TestScriptPreloadAndNoScriptFallback(t *testing.T) {
+	client := newTestRedisClient(t)
+
+	if err := LoadScripts(); err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	exists, err := client.ScriptExists(ctx, testIncrScript.script.Hash()).Result()
+	if err != nil || !exists[0] {
+		t.Fatalf("script not preloaded: %v, %v", exists, err)
+	}
+
+	var reply struct {
+		OK    bool
+		Count int64
+		Label string
+		Text  int
+	}
+	if err := testIncrScript.Run([]string{"counter"}, 2, "first").Scan(&reply); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !reply.OK || reply.Count != 2 || reply.Label != "first" || reply.Text != 2 {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	// 脚本缓存被清空后退回EVAL
+	if err := client.ScriptFlush(ctx).Err(); err != nil {
+		t.Fatalf("SCRIPT FLUSH: %v", err)
+	}
+	if err := testIncrScript.Run([]string{"counter"}, 3, "second").Scan(&reply); err != nil {
+		t.Fatalf("Run after flush: %v", err)
+	}
+	if reply.Count != 5 || reply.Label != "second" {
+		t.Errorf("reply after flush = %+v", reply)
+	}
+}
+
+func TestScriptResultScan(t *testing.T) {
+	var scalar struct{ Deleted int64 }
+	if err := (&ScriptResult{val: int64(1)}).Scan(&scalar); err != nil || scalar.Deleted != 1 {
+		t.Errorf("scalar reply = %+v, %v", scalar, err)
+	}
+
+	var short struct {
+		OK      bool
+		Balance int64
+	}
+	if err := (&ScriptResult{val: []interface{}{int64(0)}}).Scan(&short); err != nil || short.OK || short.Balance != 0 {
+		t.Errorf("short reply = %+v, %v", short, err)
+	}
+
+	var bad struct{ N int64 }
+	if err := (&ScriptResult{val: []interface{}{"abc"}}).Scan(&bad); err == nil {
+		t.Error("expected error converting non-numeric string to int64")
+	}
+	if err := (&ScriptResult{val: int64(1)}).Scan(bad); err == nil {
+		t.Error("expected error scanning into non-pointer")
+	}
+
+	failed := &ScriptResult{err: errors.New("boom")}
+	if err := failed.Scan(&bad); err == nil || failed.Err() == nil {
+		t.Error("execution error not returned")
+	}
+}
+
+func TestScriptRejectsCrossSlotKeys(t *testing.T) {
+	if err := testIncrScript.Run([]string{"a", "b"}).Err(); !errors.Is(err, ErrCrossSlot) {
+		t.Errorf("err = %v; want ErrCrossSlot", err)
+	}
+}
+
+func TestRegisterScriptTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	RegisterScript("test.incr", "return 1", nil)
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCrossSlot 多键操作的键分布在不同的槽
+var ErrCrossSlot = errors.New("keys hash to different slots")
+
+// slotCount Redis Cluster 的槽数量
+const slotCount = 16384
+
+// Slot 计算键在Redis Cluster中的槽，键包含非空的 {哈希标签} 时只对标签内容计算
+func Slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % slotCount)
+}
+
+// checkSameSlot 校验多个键是否落在同一个槽
+func checkSameSlot(keys []string) error {
+	for i := 1; i < len(keys); i++ {
+		if Slot(keys[i]) != Slot(keys[0]) {
+			return fmt.Errorf("%w: %s and %s", ErrCrossSlot, keys[0], keys[i])
+		}
+	}
+	return nil
+}
+
+// crc16 CRC16-CCITT（XMODEM），Redis Cluster 使用的键哈希算法
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Nil 键不存在时Get、HGet返回的错误
+const Nil = redis.Nil
+
+// ErrKeyExists Move的目标键已存在
+var ErrKeyExists = errors.New("target key already exists")
+
+// Store 缓存存储，覆盖业务使用的Redis操作
+// RedisStore为生产实现，MemoryStore为纯内存实现，用于不依赖Redis的测试
+type Store interface {
+	Ping() error
+
+	Get(key string) (string, error)
+	// MGet 批量读取，不存在的键对应nil，其余为string；键可以分布在不同的槽
+	MGet(keys ...string) ([]interface{}, error)
+	Set(key string, value interface{}, expiration time.Duration) error
+	SetNX(key string, value interface{}, expiration time.Duration) (bool, error)
+	Del(key string) error
+	// Move 将src的值和剩余过期时间移到dst并删除src，两个键可以在不同的槽，只支持字符串和Stream
+	// src不存在时返回false，dst已存在时返回ErrKeyExists
+	Move(src, dst string) (bool, error)
+	Incr(key string) (int64, error)
+	IncrBy(key string, value int64) (int64, error)
+	Decr(key string) (int64, error)
+	Expire(key string, expiration time.Duration) error
+	// TTL 返回剩余过期时间，键不存在时为-2，未设置过期时间时为-1
+	TTL(key string) (time.Duration, error)
+
+	HGet(key, field string) (string, error)
+	HSet(key, field string, value interface{}) error
+	HSetWithExpire(key string, values map[string]interface{}, expiration time.Duration) error
+	HGetAll(key string) (map[string]string, error)
+	HDel(key string, fields ...string) error
+	HIncrBy(key, field string, incr int64) (int64, error)
+
+	SAdd(key string, members ...interface{}) error
+	SIsMember(key string, member interface{}) (bool, error)
+	SCard(key string) (int64, error)
+
+	Publish(channel string, message interface{}) error
+	Subscribe(channels ...string) Subscription
+
+	XAdd(stream string, values map[string]interface{}) (string, error)
+	XRevRangeN(stream, start, stop string, count int64) ([]redis.XMessage, error)
+
+	// LoadScripts 预加载脚本
+	LoadScripts(scripts []*Script) error
+	// RunScript 原子执行脚本，脚本返回nil时返回(nil, nil)
+	RunScript(script *Script, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Message 订阅收到的消息
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Subscription 频道订阅
+type Subscription interface {
+	// Wait 等待订阅生效
+	Wait(ctx context.Context) error
+	// Channel 返回消息通道，订阅关闭后通道关闭
+	Channel() <-chan *Message
+	Close() error
+}
+
+var store Store
+
+// SetStore 替换包级函数使用的存储，InitRedis会设置为RedisStore，测试中可换成MemoryStore
+func SetStore(s Store) {
+	store = s
+}
+
+// formatArg 按go-redis的规则将参数转换为字符串，保证两种实现存储的值一致
+func formatArg(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 64), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.FormatInt(v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	default:
+		return "", fmt.Errorf("redis: can't marshal %T (implement encoding.BinaryMarshaler)", v)
+	}
+}
@@ -0,0 +1,280 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// forEachStore 分别在miniredis上的RedisStore和MemoryStore上运行同一用例，保证两种实现行为一致
+// advance 将存储的时钟向前拨动
+func forEachStore(t *testing.T, fn func(t *testing.T, s Store, advance func(time.Duration))) {
+	t.Run("redis", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		fn(t, NewRedisStore(client), mr.FastForward)
+	})
+	t.Run("memory", func(t *testing.T) {
+		s := NewMemoryStore()
+		fn(t, s, s.FastForward)
+	})
+}
+
+func TestStoreStrings(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		if _, err := s.Get("missing"); !errors.Is(err, Nil) {
+			t.Errorf("Get missing err = %v; want Nil", err)
+		}
+		if err := s.Set("k", 42, 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if v, err := s.Get("k"); err != nil || v != "42" {
+			t.Errorf("Get = %q, %v", v, err)
+		}
+		if ok, err := s.SetNX("k", 1, 0); err != nil || ok {
+			t.Errorf("SetNX existing = %v, %v", ok, err)
+		}
+		if n, err := s.IncrBy("k", 8); err != nil || n != 50 {
+			t.Errorf("IncrBy = %d, %v", n, err)
+		}
+		if n, err := s.Decr("k"); err != nil || n != 49 {
+			t.Errorf("Decr = %d, %v", n, err)
+		}
+		if n, err := s.Incr("fresh"); err != nil || n != 1 {
+			t.Errorf("Incr missing = %d, %v", n, err)
+		}
+		s.Set("text", "abc", 0)
+		if _, err := s.Incr("text"); err == nil {
+			t.Error("Incr on non-integer should fail")
+		}
+
+		if ttl, _ := s.TTL("missing"); ttl != -2 {
+			t.Errorf("TTL missing = %v; want -2", ttl)
+		}
+		if ttl, _ := s.TTL("k"); ttl != -1 {
+			t.Errorf("TTL persistent = %v; want -1", ttl)
+		}
+		if ok, err := s.SetNX("lock", "owner", 10*time.Second); err != nil || !ok {
+			t.Fatalf("SetNX = %v, %v", ok, err)
+		}
+		if ttl, _ := s.TTL("lock"); ttl != 10*time.Second {
+			t.Errorf("TTL = %v; want 10s", ttl)
+		}
+		advance(10 * time.Second)
+		if _, err := s.Get("lock"); !errors.Is(err, Nil) {
+			t.Errorf("expired key err = %v; want Nil", err)
+		}
+		if ok, _ := s.SetNX("lock", "other", time.Second); !ok {
+			t.Error("SetNX after expiry should succeed")
+		}
+
+		s.Del("k")
+		if _, err := s.Get("k"); !errors.Is(err, Nil) {
+			t.Errorf("deleted key err = %v; want Nil", err)
+		}
+
+		s.HSet("hash", "f", 1)
+		values, err := s.MGet("fresh", "missing", "hash")
+		if want := []interface{}{"1", nil, nil}; err != nil || !reflect.DeepEqual(values, want) {
+			t.Errorf("MGet = %v, %v; want %v", values, err, want)
+		}
+	})
+}
+
+func TestStoreMove(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		if moved, err := s.Move("missing", "dst"); err != nil || moved {
+			t.Errorf("Move missing = %v, %v; want false", moved, err)
+		}
+
+		s.Set("old:{a}:stock", 7, time.Minute)
+		if moved, err := s.Move("old:{a}:stock", "new:{b}:stock"); err != nil || !moved {
+			t.Fatalf("Move string = %v, %v", moved, err)
+		}
+		if v, _ := s.Get("new:{b}:stock"); v != "7" {
+			t.Errorf("moved value = %q; want 7", v)
+		}
+		if ttl, _ := s.TTL("new:{b}:stock"); ttl != time.Minute {
+			t.Errorf("moved TTL = %v; want 1m", ttl)
+		}
+		if _, err := s.Get("old:{a}:stock"); !errors.Is(err, Nil) {
+			t.Errorf("source still present: %v", err)
+		}
+
+		// Stream分批复制，保留消息ID
+		var ids []string
+		for i := 0; i < 1005; i++ {
+			id, _ := s.XAdd("old:{a}:ledger", map[string]interface{}{"n": i})
+			ids = append(ids, id)
+		}
+		if moved, err := s.Move("old:{a}:ledger", "new:{b}:ledger"); err != nil || !moved {
+			t.Fatalf("Move stream = %v, %v", moved, err)
+		}
+		messages, err := s.XRevRangeN("new:{b}:ledger", "+", "-", 0)
+		if err != nil || len(messages) != len(ids) {
+			t.Fatalf("moved stream has %d messages, %v; want %d", len(messages), err, len(ids))
+		}
+		if messages[0].ID != ids[len(ids)-1] || messages[len(messages)-1].ID != ids[0] || messages[0].Values["n"] != "1004" {
+			t.Errorf("moved stream = %s .. %s", messages[0].ID, messages[len(messages)-1].ID)
+		}
+
+		s.Set("src", 1, 0)
+		s.Set("dst", 2, 0)
+		if _, err := s.Move("src", "dst"); !errors.Is(err, ErrKeyExists) {
+			t.Errorf("Move onto existing key err = %v; want ErrKeyExists", err)
+		}
+		if v, _ := s.Get("dst"); v != "2" {
+			t.Errorf("existing target overwritten with %q", v)
+		}
+	})
+}
+
+func TestStoreHashesAndSets(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		s.HSet("h", "a", 1)
+		if n, err := s.HIncrBy("h", "a", 2); err != nil || n != 3 {
+			t.Errorf("HIncrBy = %d, %v", n, err)
+		}
+		if _, err := s.HGet("h", "b"); !errors.Is(err, Nil) {
+			t.Errorf("HGet missing field err = %v; want Nil", err)
+		}
+		if err := s.HSetWithExpire("h", map[string]interface{}{"b": "x", "c": true}, time.Minute); err != nil {
+			t.Fatalf("HSetWithExpire: %v", err)
+		}
+		fields, err := s.HGetAll("h")
+		if want := map[string]string{"b": "x", "c": "1"}; err != nil || !reflect.DeepEqual(fields, want) {
+			t.Errorf("HGetAll = %v, %v; want %v", fields, err, want)
+		}
+		if ttl, _ := s.TTL("h"); ttl != time.Minute {
+			t.Errorf("hash TTL = %v; want 1m", ttl)
+		}
+		s.HDel("h", "b", "c")
+		if fields, err := s.HGetAll("h"); err != nil || len(fields) != 0 {
+			t.Errorf("HGetAll after HDel = %v, %v", fields, err)
+		}
+		if _, err := s.HGet("strkey", "a"); !errors.Is(err, Nil) {
+			t.Errorf("HGet missing key err = %v", err)
+		}
+		s.Set("strkey", 1, 0)
+		if _, err := s.HGet("strkey", "a"); err == nil || errors.Is(err, Nil) {
+			t.Errorf("HGet on string err = %v; want WRONGTYPE", err)
+		}
+
+		s.SAdd("s", "u1", "u2", 3)
+		s.SAdd("s", "u1")
+		if n, err := s.SCard("s"); err != nil || n != 3 {
+			t.Errorf("SCard = %d, %v", n, err)
+		}
+		if ok, _ := s.SIsMember("s", 3); !ok {
+			t.Error("SIsMember(3) = false")
+		}
+		if ok, _ := s.SIsMember("s", "u9"); ok {
+			t.Error("SIsMember(u9) = true")
+		}
+	})
+}
+
+func TestStoreStreams(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		var ids []string
+		for _, reason := range []string{"a", "b", "c"} {
+			id, err := s.XAdd("stream", map[string]interface{}{"reason": reason, "delta": -1})
+			if err != nil {
+				t.Fatalf("XAdd: %v", err)
+			}
+			ids = append(ids, id)
+		}
+
+		messages, err := s.XRevRangeN("stream", "+", "-", 2)
+		if err != nil || len(messages) != 2 || messages[0].ID != ids[2] || messages[1].ID != ids[1] {
+			t.Fatalf("XRevRangeN = %+v, %v", messages, err)
+		}
+		if messages[0].Values["reason"] != "c" || messages[0].Values["delta"] != "-1" {
+			t.Errorf("values = %v", messages[0].Values)
+		}
+		if messages, _ := s.XRevRangeN("stream", ids[1], "-", 5); len(messages) != 2 || messages[0].ID != ids[1] {
+			t.Errorf("XRevRangeN from cursor = %+v", messages)
+		}
+		if messages, err := s.XRevRangeN("none", "+", "-", 5); err != nil || len(messages) != 0 {
+			t.Errorf("XRevRangeN missing = %+v, %v", messages, err)
+		}
+	})
+}
+
+func TestStorePubSub(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		sub := s.Subscribe("events")
+		if err := sub.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait: %v", err)
+		}
+		if err := s.Publish("events", 7); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+
+		select {
+		case msg := <-sub.Channel():
+			if msg.Channel != "events" || msg.Payload != "7" {
+				t.Errorf("message = %+v", msg)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+
+		sub.Close()
+		select {
+		case _, ok := <-sub.Channel():
+			if ok {
+				t.Error("unexpected message after Close")
+			}
+		case <-time.After(time.Second):
+			t.Error("channel not closed after Close")
+		}
+	})
+}
+
+func TestStoreScripts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s Store, advance func(time.Duration)) {
+		SetStore(s)
+		if err := LoadScripts(); err != nil {
+			t.Fatalf("LoadScripts: %v", err)
+		}
+
+		var reply struct {
+			OK    bool
+			Count int64
+			Label string
+			Text  string
+		}
+		if err := testIncrScript.Run([]string{"counter"}, 4, "x").Scan(&reply); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		if !reply.OK || reply.Count != 4 || reply.Label != "x" || reply.Text != "4" {
+			t.Errorf("reply = %+v", reply)
+		}
+		if v, _ := s.Get("counter"); v != "4" {
+			t.Errorf("counter = %q; want 4", v)
+		}
+
+		s.Set("counter", "abc", 0)
+		if err := testIncrScript.Run([]string{"counter"}, 1, "x").Err(); err == nil {
+			t.Error("script error not returned")
+		}
+	})
+}
+
+func TestMemoryStoreRequiresGoImplementation(t *testing.T) {
+	s := NewMemoryStore()
+	script := &Script{name: "test.lua_only"}
+	if err := s.LoadScripts([]*Script{script}); err == nil {
+		t.Error("LoadScripts should reject scripts without a Go implementation")
+	}
+	if _, err := s.RunScript(script, nil); err == nil {
+		t.Error("RunScript should fail without a Go implementation")
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -9,6 +11,8 @@ type Config struct {
 	Database DatabaseConfig
 	Redis    RedisConfig
 	Seckill  SeckillConfig
+	Outbox   OutboxConfig
+	Auth     AuthConfig
 }
 
 type ServerConfig struct {
@@ -19,6 +23,10 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
+	// Driver 数据库驱动：mysql、postgres 或 sqlite（本地测试）
+	Driver string
+	// DSN 完整连接串，设置后忽略Host等字段；sqlite 为数据库文件路径
+	DSN          string
 	Host         string
 	Port         string
 	User         string
@@ -26,13 +34,34 @@ type DatabaseConfig struct {
 	Database     string
 	MaxOpenConns int
 	MaxIdleConns int
+	// AutoMigrate 启动时执行GORM AutoMigrate，生产环境应关闭并使用 migrate 子命令
+	AutoMigrate bool
+	// ReplicaDSNs 只读从库DSN，为空时读写都走主库
+	ReplicaDSNs []string
+	// ReplicaMaxLag 从库复制延迟超过该值（秒）时健康检查报告为不健康
+	ReplicaMaxLag int
+	// ReplicaLagInterval 后台采样从库复制延迟的间隔（秒），健康检查返回最近一次采样结果
+	ReplicaLagInterval int
+	// ReplicaLagTimeout 单次复制延迟查询的超时时间（秒）
+	ReplicaLagTimeout int
+	// ReadYourWritesWindow 写入后该时长（秒）内的订单查询和商品缓存回填强制走主库
+	ReadYourWritesWindow int
+	// OrderShards 订单表按用户ID哈希拆分的分片数，1表示不分片
+	OrderShards int
 }
 
 type RedisConfig struct {
-	Addr     string
-	Password string
-	DB       int
-	PoolSize int
+	// Mode 部署模式：single、sentinel 或 cluster
+	Mode string
+	Addr string
+	// Addrs 哨兵或集群节点地址，为空时使用Addr
+	Addrs []string
+	// MasterName 哨兵模式下的主节点名称
+	MasterName       string
+	Password         string
+	SentinelPassword string
+	DB               int
+	PoolSize         int
 }
 
 type SeckillConfig struct {
@@ -44,6 +73,74 @@ type SeckillConfig struct {
 	PreheatKey       string
 	MaxConcurrency   int
 	RateLimitPerUser int
+	ReservePrefix    string
+	// LedgerPrefix 库存流水Redis Stream前缀，每个商品一个Stream
+	LedgerPrefix string
+	// LedgerMaxLen 每个商品保留的库存流水条数，写入时按 MAXLEN ~ 近似裁剪最早的流水
+	LedgerMaxLen int
+	// ReservationWindow 开售前允许预约的时长（秒）
+	ReservationWindow int
+	EligiblePrefix    string
+	RYWPrefix         string
+	// EligibilityCacheTTL 购买资格校验结果缓存时长（秒）
+	EligibilityCacheTTL int
+	ACLKey              string
+	ACLChannel          string
+	// ACLRefreshInterval 黑白名单本地副本的兜底刷新间隔（秒）
+	ACLRefreshInterval int
+	ProductCachePrefix string
+	// ProductCacheTTL 商品详情缓存时长（秒），实际过期时间附加最多10%的随机抖动
+	ProductCacheTTL int
+	// ProductNegativeTTL 不存在的商品ID的缓存时长（秒）
+	ProductNegativeTTL int
+	// LocalCacheSize 进程内商品缓存的最大条目数，0表示关闭本地缓存
+	LocalCacheSize int
+	// LocalCacheTTL 进程内商品缓存时长（秒），应明显短于ProductCacheTTL
+	LocalCacheTTL int
+	// LocalCacheChannel 本地缓存失效通知频道
+	LocalCacheChannel string
+	// ProductBloomEnabled 是否用布隆过滤器拦截不存在的商品ID
+	ProductBloomEnabled bool
+	// ProductBloomKey 商品ID布隆过滤器位图的键名前缀
+	ProductBloomKey string
+	// ProductBloomCapacity 布隆过滤器预期容纳的商品数，超出后误判率上升
+	ProductBloomCapacity int
+	// ProductBloomFPRate 布隆过滤器在容量内的误判率
+	ProductBloomFPRate float64
+	// ProductBloomRebuild 定期从数据库重建布隆过滤器的间隔（秒），0表示不定期重建
+	ProductBloomRebuild int
+	// BundleMaxQuantity 组合购中单个商品的最大购买数量
+	BundleMaxQuantity int
+}
+
+// AuthConfig 请求身份认证配置，身份令牌由登录服务用对应的密钥签发
+type AuthConfig struct {
+	// UserSecret 校验用户身份令牌的HMAC密钥，为空时不认证，直接信任请求体中的user_id（仅用于开发环境）
+	UserSecret string
+	// AdminSecret 校验管理员身份令牌的HMAC密钥，为空时管理接口不认证，审计日志中的操作人标记为 unverified
+	AdminSecret string
+	// AllowUnauthenticated 允许在未配置密钥时启动，默认缺少任一密钥时拒绝启动（仅用于开发环境）
+	AllowUnauthenticated bool
+}
+
+// OutboxConfig 发件箱中继配置
+type OutboxConfig struct {
+	// Publisher 事件投递方式：log、http 或 redis
+	Publisher string
+	// HTTPURL http 投递方式的目标地址，事件以JSON POST
+	HTTPURL string
+	// HTTPTimeout http 投递超时（秒）
+	HTTPTimeout int
+	// Stream redis 投递方式写入的Stream
+	Stream string
+	// PollInterval 轮询发件箱的间隔（毫秒）
+	PollInterval int
+	BatchSize    int
+	// MaxAttempts 超过该投递次数后事件标记为dead，不再重试
+	MaxAttempts int
+	// MaxBackoff 重试退避上限（秒）
+	MaxBackoff int
+	LockKey    string
 }
 
 func Load() *Config {
@@ -55,29 +152,82 @@ func Load() *Config {
 			WriteTimeout: 30,
 		},
 		Database: DatabaseConfig{
-			Host:         getEnv("DB_HOST", "localhost"),
-			Port:         getEnv("DB_PORT", "3306"),
-			User:         getEnv("DB_USER", "root"),
-			Password:     getEnv("DB_PASSWORD", "password"),
-			Database:     getEnv("DB_NAME", "seckill"),
-			MaxOpenConns: 100,
-			MaxIdleConns: 10,
+			Driver:               getEnv("DB_DRIVER", "mysql"),
+			DSN:                  getEnv("DB_DSN", ""),
+			Host:                 getEnv("DB_HOST", "localhost"),
+			Port:                 getEnv("DB_PORT", "3306"),
+			User:                 getEnv("DB_USER", "root"),
+			Password:             getEnv("DB_PASSWORD", "password"),
+			Database:             getEnv("DB_NAME", "seckill"),
+			MaxOpenConns:         100,
+			MaxIdleConns:         10,
+			AutoMigrate:          getEnvBool("DB_AUTO_MIGRATE", false),
+			ReplicaDSNs:          getEnvList("DB_REPLICA_DSNS"),
+			ReplicaMaxLag:        getEnvInt("DB_REPLICA_MAX_LAG", 10),
+			ReplicaLagInterval:   getEnvInt("DB_REPLICA_LAG_INTERVAL", 5),
+			ReplicaLagTimeout:    getEnvInt("DB_REPLICA_LAG_TIMEOUT", 2),
+			ReadYourWritesWindow: getEnvInt("DB_READ_YOUR_WRITES_WINDOW", 5),
+			OrderShards:          getEnvInt("DB_ORDER_SHARDS", 1),
 		},
 		Redis: RedisConfig{
-			Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
-			Password: getEnv("REDIS_PASSWORD", ""),
-			DB:       0,
-			PoolSize: 100,
+			Mode:             getEnv("REDIS_MODE", "single"),
+			Addr:             getEnv("REDIS_ADDR", "localhost:6379"),
+			Addrs:            getEnvList("REDIS_ADDRS"),
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			DB:               0,
+			PoolSize:         100,
 		},
+		// 库存、下单标记、库存流水和令牌会在同一个Lua脚本中访问，前缀后面会拼上商品的哈希标签，
+		// 如 seckill:stock:{p42}:42，同一库存分组的商品共用 {g:<分组>}，组合购只能在同组内进行
 		Seckill: SeckillConfig{
-			TokenPrefix:      "seckill:token:",
-			StockPrefix:      "seckill:stock:",
-			OrderPrefix:      "seckill:order:",
-			LockPrefix:       "seckill:lock:",
-			TokenExpire:      3600,
-			PreheatKey:       "seckill:preheat:",
-			MaxConcurrency:   10000,
-			RateLimitPerUser: 5,
+			TokenPrefix:          "seckill:token:",
+			StockPrefix:          "seckill:stock:",
+			OrderPrefix:          "seckill:order:",
+			LockPrefix:           "seckill:lock:",
+			TokenExpire:          3600,
+			PreheatKey:           "seckill:preheat:",
+			MaxConcurrency:       10000,
+			RateLimitPerUser:     5,
+			ReservePrefix:        "seckill:reserve:",
+			LedgerPrefix:         "seckill:ledger:",
+			LedgerMaxLen:         getEnvInt("SECKILL_LEDGER_MAX_LEN", 10000),
+			ReservationWindow:    getEnvInt("SECKILL_RESERVATION_WINDOW", 86400),
+			EligiblePrefix:       "seckill:eligible:",
+			RYWPrefix:            "seckill:ryw:",
+			EligibilityCacheTTL:  getEnvInt("SECKILL_ELIGIBILITY_CACHE_TTL", 300),
+			ACLKey:               "seckill:acl:rules",
+			ACLChannel:           "seckill:acl:changed",
+			ACLRefreshInterval:   getEnvInt("SECKILL_ACL_REFRESH_INTERVAL", 30),
+			ProductCachePrefix:   "seckill:product:",
+			ProductCacheTTL:      getEnvInt("SECKILL_PRODUCT_CACHE_TTL", 300),
+			ProductNegativeTTL:   getEnvInt("SECKILL_PRODUCT_NEGATIVE_TTL", 30),
+			LocalCacheSize:       getEnvInt("SECKILL_LOCAL_CACHE_SIZE", 10000),
+			LocalCacheTTL:        getEnvInt("SECKILL_LOCAL_CACHE_TTL", 5),
+			LocalCacheChannel:    "seckill:cache:invalidate",
+			ProductBloomEnabled:  getEnvBool("SECKILL_BLOOM_ENABLED", true),
+			ProductBloomKey:      "seckill:bloom:product",
+			ProductBloomCapacity: getEnvInt("SECKILL_BLOOM_CAPACITY", 1000000),
+			ProductBloomFPRate:   getEnvFloat("SECKILL_BLOOM_FP_RATE", 0.001),
+			ProductBloomRebuild:  getEnvInt("SECKILL_BLOOM_REBUILD_INTERVAL", 3600),
+			BundleMaxQuantity:    getEnvInt("SECKILL_BUNDLE_MAX_QUANTITY", 5),
+		},
+		Outbox: OutboxConfig{
+			Publisher:    getEnv("OUTBOX_PUBLISHER", "log"),
+			HTTPURL:      getEnv("OUTBOX_HTTP_URL", ""),
+			HTTPTimeout:  getEnvInt("OUTBOX_HTTP_TIMEOUT", 5),
+			Stream:       getEnv("OUTBOX_STREAM", "seckill:events"),
+			PollInterval: getEnvInt("OUTBOX_POLL_INTERVAL", 1000),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts:  getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+			MaxBackoff:   getEnvInt("OUTBOX_MAX_BACKOFF", 300),
+			LockKey:      "seckill:lock:outbox-relay",
+		},
+		Auth: AuthConfig{
+			UserSecret:           getEnv("AUTH_USER_SECRET", ""),
+			AdminSecret:          getEnv("AUTH_ADMIN_SECRET", ""),
+			AllowUnauthenticated: getEnvBool("AUTH_ALLOW_UNAUTHENTICATED", false),
 		},
 	}
 }
@@ -89,3 +239,40 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList 读取逗号分隔的列表，忽略空项
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go-seckill/models"
+	"go-seckill/service"
+)
+
+type AccessController struct {
+	accessListService *service.AccessListService
+}
+
+func NewAccessController(accessListService *service.AccessListService) *AccessController {
+	return &AccessController{
+		accessListService: accessListService,
+	}
+}
+
+// ListRules 获取黑白名单规则（管理接口）
+func (c *AccessController) ListRules(ctx *gin.Context) {
+	rules, err := c.accessListService.ListRules()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: rules,
+	})
+}
+
+// AddRule 新增黑白名单规则（管理接口）
+func (c *AccessController) AddRule(ctx *gin.Context) {
+	var rule models.AccessRule
+	if err := ctx.ShouldBindJSON(&rule); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	if err := c.accessListService.AddRule(ctx.Request.Context(), &rule); err != nil {
+		if errors.Is(err, service.ErrInvalidAccessRule) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "access rule saved successfully",
+		Data: rule,
+	})
+}
+
+// RemoveRule 删除黑白名单规则（管理接口）
+func (c *AccessController) RemoveRule(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid rule id",
+		})
+		return
+	}
+
+	if err := c.accessListService.RemoveRule(ctx.Request.Context(), uint(id)); err != nil {
+		ctx.JSON(http.StatusNotFound, Response{
+			Code: 404,
+			Msg:  "access rule not found",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "access rule removed successfully",
+	})
+}
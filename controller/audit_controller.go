@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go-seckill/repository"
+	"go-seckill/service"
+)
+
+type AuditController struct {
+	auditService *service.AuditService
+}
+
+func NewAuditController(auditService *service.AuditService) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+	}
+}
+
+// ListAuditLogs 分页查询审计日志（管理接口），按时间倒序
+func (c *AuditController) ListAuditLogs(ctx *gin.Context) {
+	var req struct {
+		Actor      string    `form:"actor"`
+		Action     string    `form:"action"`
+		TargetType string    `form:"target_type"`
+		TargetID   string    `form:"target_id"`
+		Since      time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until      time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		Cursor     string    `form:"cursor"`
+		Limit      int       `form:"limit" binding:"omitempty,min=1,max=200"`
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	entries, next, err := c.auditService.ListAuditLogs(repository.AuditFilter{
+		Actor:      req.Actor,
+		Action:     req.Action,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Since:      req.Since,
+		Until:      req.Until,
+	}, req.Cursor, req.Limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"items": entries, "next_cursor": next},
+	})
+}
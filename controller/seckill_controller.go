@@ -1,11 +1,17 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go-seckill/middleware"
 	"go-seckill/models"
+	"go-seckill/repository"
 	"go-seckill/service"
 )
 
@@ -26,10 +32,45 @@ type Response struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
-// GetProducts 获取商品列表
+// GetProducts 获取商品列表，支持按销售状态、时间段和名称过滤
+// 带cursor或limit参数时分页返回 {items, next_cursor}，否则与旧版本一样返回全部商品的数组
 func (c *SeckillController) GetProducts(ctx *gin.Context) {
-	products, err := c.seckillService.ListProducts()
+	var req struct {
+		Status string    `form:"status"`
+		From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+		To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+		Name   string    `form:"name"`
+		Cursor string    `form:"cursor"`
+		Limit  int       `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	paged := req.Cursor != "" || req.Limit != 0
+	if paged && req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	products, next, err := c.seckillService.ListProducts(service.ProductListQuery{
+		Status: req.Status,
+		From:   req.From,
+		To:     req.To,
+		Name:   req.Name,
+		Cursor: req.Cursor,
+		Limit:  req.Limit,
+	})
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, Response{
 			Code: 500,
 			Msg:  err.Error(),
@@ -37,10 +78,53 @@ func (c *SeckillController) GetProducts(ctx *gin.Context) {
 		return
 	}
 
+	if !paged {
+		ctx.JSON(http.StatusOK, Response{
+			Code: 200,
+			Msg:  "success",
+			Data: products,
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"items": products, "next_cursor": next},
+	})
+}
+
+// GetLiveStocks 批量查询商品实时剩余库存，ids为逗号分隔的商品ID
+func (c *SeckillController) GetLiveStocks(ctx *gin.Context) {
+	var ids []uint
+	for _, part := range strings.Split(ctx.Query("ids"), ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  "invalid product id " + part,
+			})
+			return
+		}
+		ids = append(ids, uint(id))
+	}
+
+	stocks, err := c.seckillService.GetLiveStocks(ids)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			ctx.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		return
+	}
+
 	ctx.JSON(http.StatusOK, Response{
 		Code: 200,
 		Msg:  "success",
-		Data: products,
+		Data: stocks,
 	})
 }
 
@@ -89,6 +173,55 @@ func (c *SeckillController) GenerateToken(ctx *gin.Context) {
 
 	token, err := c.seckillService.GenerateToken(req.UserID, req.ProductID)
 	if err != nil {
+		var ineligible *service.IneligibleError
+		if errors.As(err, &ineligible) {
+			ctx.JSON(http.StatusForbidden, Response{
+				Code: 403,
+				Msg:  err.Error(),
+				Data: ineligible,
+			})
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"token": token},
+	})
+}
+
+// GenerateBundleToken 生成组合购令牌
+func (c *SeckillController) GenerateBundleToken(ctx *gin.Context) {
+	var req struct {
+		UserID string               `json:"user_id" binding:"required"`
+		Items  []service.BundleItem `json:"items" binding:"required,min=1,dive"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	token, err := c.seckillService.GenerateBundleToken(req.UserID, req.Items)
+	if err != nil {
+		var ineligible *service.IneligibleError
+		if errors.As(err, &ineligible) {
+			ctx.JSON(http.StatusForbidden, Response{
+				Code: 403,
+				Msg:  err.Error(),
+				Data: ineligible,
+			})
+			return
+		}
 		ctx.JSON(http.StatusBadRequest, Response{
 			Code: 400,
 			Msg:  err.Error(),
@@ -153,11 +286,149 @@ func (c *SeckillController) Seckill(ctx *gin.Context) {
 	})
 }
 
+// SeckillBundle 组合购秒杀接口
+func (c *SeckillController) SeckillBundle(ctx *gin.Context) {
+	var req struct {
+		UserID string               `json:"user_id" binding:"required"`
+		Token  string               `json:"token" binding:"required"`
+		Items  []service.BundleItem `json:"items" binding:"required,min=1,dive"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	// 检查用户是否已经购买过组合中的商品
+	for _, item := range req.Items {
+		hasOrder, err := c.seckillService.CheckUserOrder(req.UserID, item.ProductID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, Response{
+				Code: 500,
+				Msg:  err.Error(),
+			})
+			return
+		}
+
+		if hasOrder {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  "user already has an order",
+			})
+			return
+		}
+	}
+
+	order, err := c.seckillService.SeckillBundle(req.UserID, req.Items, req.Token)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "seckill success",
+		Data: order,
+	})
+}
+
+// Reserve 预约秒杀
+func (c *SeckillController) Reserve(ctx *gin.Context) {
+	var req struct {
+		ProductID uint   `json:"product_id" binding:"required"`
+		UserID    string `json:"user_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	if err := c.seckillService.Reserve(req.UserID, req.ProductID); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "reservation success",
+	})
+}
+
+// GetUserOrders 分页获取用户订单，按下单时间倒序，只能查询认证身份本人的订单
+func (c *SeckillController) GetUserOrders(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if ctx.GetString(middleware.PrincipalKey) != userID {
+		ctx.JSON(http.StatusForbidden, Response{
+			Code: 403,
+			Msg:  "cannot list orders of another user",
+		})
+		return
+	}
+
+	var req struct {
+		Status string `form:"status"`
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=100"`
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	orders, next, err := c.seckillService.ListUserOrders(userID, req.Status, req.Cursor, req.Limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidQuery) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"items": orders, "next_cursor": next},
+	})
+}
+
 // GetOrder 获取订单信息
 func (c *SeckillController) GetOrder(ctx *gin.Context) {
 	orderNo := ctx.Param("orderNo")
-	
+
 	order, err := c.seckillService.GetOrder(orderNo)
+	if errors.Is(err, repository.ErrUnroutableOrder) {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid order number",
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, Response{
 			Code: 404,
@@ -184,7 +455,14 @@ func (c *SeckillController) CreateProduct(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.seckillService.CreateProduct(&product); err != nil {
+	if err := c.seckillService.CreateProduct(ctx.Request.Context(), &product); err != nil {
+		if errors.Is(err, service.ErrInvalidProduct) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, Response{
 			Code: 500,
 			Msg:  err.Error(),
@@ -199,6 +477,319 @@ func (c *SeckillController) CreateProduct(ctx *gin.Context) {
 	})
 }
 
+// UpdateProduct 修改商品信息（管理接口），开售后修改价格或库存需要 ?force=true
+func (c *SeckillController) UpdateProduct(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	var product models.Product
+	if err := ctx.ShouldBindJSON(&product); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	updated, err := c.seckillService.UpdateProduct(ctx.Request.Context(), uint(id), &product, ctx.Query("force") == "true")
+	if err != nil {
+		c.respondProductError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "product updated successfully",
+		Data: updated,
+	})
+}
+
+// DeleteProduct 软删除商品（管理接口），秒杀进行中的商品需要 ?force=true
+func (c *SeckillController) DeleteProduct(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	if err := c.seckillService.DeleteProduct(ctx.Request.Context(), uint(id), ctx.Query("force") == "true"); err != nil {
+		c.respondProductError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "product deleted successfully",
+	})
+}
+
+// RestoreProduct 恢复已删除的商品（管理接口）
+func (c *SeckillController) RestoreProduct(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	product, err := c.seckillService.RestoreProduct(ctx.Request.Context(), uint(id))
+	if err != nil {
+		c.respondProductError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "product restored successfully",
+		Data: product,
+	})
+}
+
+// respondProductError 将商品管理接口的错误映射为HTTP状态码
+func (c *SeckillController) respondProductError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidProduct):
+		ctx.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+	case errors.Is(err, service.ErrProductStarted), errors.Is(err, service.ErrInsufficientStock):
+		ctx.JSON(http.StatusConflict, Response{Code: 409, Msg: err.Error()})
+	case errors.Is(err, repository.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, Response{Code: 404, Msg: "product not found"})
+	default:
+		ctx.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+	}
+}
+
+// UpdateProductRules 更新商品购买资格规则（管理接口）
+func (c *SeckillController) UpdateProductRules(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	var req struct {
+		Rules []models.RuleConfig `json:"rules"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	product, err := c.seckillService.UpdateProductRules(ctx.Request.Context(), uint(id), req.Rules)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidProduct) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  err.Error(),
+			})
+			return
+		}
+		ctx.JSON(http.StatusNotFound, Response{
+			Code: 404,
+			Msg:  "product not found",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "product rules updated successfully",
+		Data: product,
+	})
+}
+
+// GetProductStats 获取商品统计信息（管理接口）
+func (c *SeckillController) GetProductStats(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	stats, err := c.seckillService.GetProductStats(uint(id))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, Response{
+			Code: 404,
+			Msg:  "product not found",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: stats,
+	})
+}
+
+// GetCacheStats 获取本实例进程内商品缓存的命中统计（管理接口）
+func (c *SeckillController) GetCacheStats(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"products": c.seckillService.CacheStats()},
+	})
+}
+
+// QueryOrders 按条件查询订单（管理接口），订单分片时汇总所有分片
+func (c *SeckillController) QueryOrders(ctx *gin.Context) {
+	var req struct {
+		UserID    string    `form:"user_id"`
+		ProductID uint      `form:"product_id"`
+		Status    string    `form:"status"`
+		Since     time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+		Until     time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+		Limit     int       `form:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 100
+	}
+
+	orders, err := c.seckillService.QueryOrders(repository.OrderQuery{
+		UserID:    req.UserID,
+		ProductID: req.ProductID,
+		Status:    req.Status,
+		Since:     req.Since,
+		Until:     req.Until,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, Response{
+			Code: 500,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: orders,
+	})
+}
+
+// AdjustStock 调整商品Redis库存（管理接口），变动记入库存流水
+func (c *SeckillController) AdjustStock(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	var req struct {
+		Delta int64  `json:"delta" binding:"required"`
+		Note  string `json:"note" binding:"required,max=255"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	// 请求未携带X-Request-ID时生成一个作为流水的关联ID
+	correlationID := ctx.GetHeader("X-Request-ID")
+	if correlationID == "" {
+		correlationID = uuid.New().String()
+	}
+
+	balance, err := c.seckillService.AdjustStock(ctx.Request.Context(), uint(id), req.Delta, req.Note, correlationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidProduct):
+			ctx.JSON(http.StatusBadRequest, Response{Code: 400, Msg: err.Error()})
+		case errors.Is(err, service.ErrInsufficientStock):
+			ctx.JSON(http.StatusConflict, Response{Code: 409, Msg: err.Error(), Data: gin.H{"stock": balance}})
+		case errors.Is(err, repository.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, Response{Code: 404, Msg: "product not found"})
+		default:
+			ctx.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "stock adjusted successfully",
+		Data: gin.H{"stock": balance, "correlation_id": correlationID},
+	})
+}
+
+// GetStockLedger 分页查询商品库存流水（管理接口），按时间倒序
+func (c *SeckillController) GetStockLedger(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 32)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  "invalid product id",
+		})
+		return
+	}
+
+	var req struct {
+		Cursor string `form:"cursor"`
+		Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"`
+	}
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, Response{
+			Code: 400,
+			Msg:  err.Error(),
+		})
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+
+	entries, next, err := c.seckillService.ListStockLedger(uint(id), req.Cursor, req.Limit)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			ctx.JSON(http.StatusNotFound, Response{Code: 404, Msg: "product not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, Response{Code: 500, Msg: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, Response{
+		Code: 200,
+		Msg:  "success",
+		Data: gin.H{"entries": entries, "next_cursor": next},
+	})
+}
+
 // UpdateOrderStatus 更新订单状态（管理接口）
 func (c *SeckillController) UpdateOrderStatus(ctx *gin.Context) {
 	var req struct {
@@ -214,7 +805,14 @@ func (c *SeckillController) UpdateOrderStatus(ctx *gin.Context) {
 		return
 	}
 
-	if err := c.seckillService.UpdateOrderStatus(req.OrderNo, req.Status); err != nil {
+	if err := c.seckillService.UpdateOrderStatus(ctx.Request.Context(), req.OrderNo, req.Status); err != nil {
+		if errors.Is(err, repository.ErrUnroutableOrder) {
+			ctx.JSON(http.StatusBadRequest, Response{
+				Code: 400,
+				Msg:  "invalid order number",
+			})
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, Response{
 			Code: 500,
 			Msg:  err.Error(),
@@ -227,4 +825,3 @@ func (c *SeckillController) UpdateOrderStatus(ctx *gin.Context) {
 		Msg:  "order status updated successfully",
 	})
 }
-
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/middleware"
+	"go-seckill/repository"
+	"go-seckill/service"
+	"go-seckill/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetUserOrdersOnlyListsOwnOrders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cache.SetStore(cache.NewMemoryStore())
+	const secret = "test-secret"
+	c := NewSeckillController(service.NewSeckillService(config.Load(), repository.NewMemoryRepositories()))
+	r := gin.New()
+	r.GET("/users/:id/orders", middleware.AuthMiddleware(secret), c.GetUserOrders)
+
+	token := utils.SignIdentity(secret, "u1", time.Now().Add(time.Hour))
+	for _, tc := range []struct {
+		name, path, token string
+		want              int
+	}{
+		{"own orders", "/users/u1/orders", token, http.StatusOK},
+		{"another user's orders", "/users/u2/orders", token, http.StatusForbidden},
+		{"no token", "/users/u1/orders", "", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s: status = %d; want %d", tc.name, w.Code, tc.want)
+		}
+	}
+}
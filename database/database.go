@@ -8,46 +8,102 @@ import (
 	"go-seckill/config"
 	"go-seckill/models"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
-func InitDB(cfg *config.Config) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.Database,
-	)
-
-	var err error
-	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
+// 支持的数据库驱动
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
 
+func InitDB(cfg *config.Config) error {
+	dialector, err := newDialector(cfg.Database.Driver, primaryDSN(cfg))
 	if err != nil {
-		return fmt.Errorf("failed to connect database: %w", err)
+		return err
 	}
 
-	sqlDB, err := DB.DB()
+	DB, err = openDB(cfg, dialector)
 	if err != nil {
-		return fmt.Errorf("failed to get database instance: %w", err)
+		return err
 	}
 
-	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
-	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	if err := initReplicas(cfg); err != nil {
+		return err
+	}
 
-	// 自动迁移
-	if err := DB.AutoMigrate(&models.Product{}, &models.Order{}); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
+	// 自动迁移仅用于开发环境，生产环境通过 migrate 子命令执行版本化迁移
+	if cfg.Database.AutoMigrate {
+		if err := DB.AutoMigrate(&models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Reservation{}, &models.UserProfile{}, &models.AccessRule{}, &models.OutboxEvent{}, &models.AuditLog{}); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
 	}
 
-	log.Println("Database connected successfully")
+	log.Printf("Database connected successfully (%s)", cfg.Database.Driver)
 	return nil
 }
 
+// primaryDSN 返回主库连接串，未配置DB_DSN时按驱动拼接
+func primaryDSN(cfg *config.Config) string {
+	db := cfg.Database
+	if db.DSN != "" {
+		return db.DSN
+	}
+	switch db.Driver {
+	case DriverPostgres:
+		return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable TimeZone=Local",
+			db.Host, db.Port, db.User, db.Password, db.Database)
+	case DriverSQLite:
+		return db.Database + ".db?_pragma=busy_timeout(5000)"
+	default:
+		return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+			db.User, db.Password, db.Host, db.Port, db.Database)
+	}
+}
+
+// newDialector 按驱动名创建GORM方言
+func newDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case DriverMySQL:
+		return mysql.Open(dsn), nil
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+	case DriverSQLite:
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, expected mysql|postgres|sqlite", driver)
+	}
+}
+
+// openDB 打开数据库连接并设置连接池参数
+func openDB(cfg *config.Config, dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+		// 表结构以迁移文件为准，迁移中不创建外键
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database instance: %w", err)
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	if dialector.Name() == DriverSQLite {
+		// SQLite 同一时刻只允许一个写连接，串行化访问避免 database is locked
+		sqlDB.SetMaxOpenConns(1)
+	}
+	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(time.Hour)
+	return db, nil
+}
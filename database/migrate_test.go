@@ -0,0 +1,115 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go-seckill/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	reference, err := LoadMigrations(DriverMySQL)
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(reference) == 0 {
+		t.Fatal("no migrations found")
+	}
+
+	for _, dialect := range []string{DriverMySQL, DriverPostgres, DriverSQLite} {
+		migrations, err := LoadMigrations(dialect)
+		if err != nil {
+			t.Fatalf("LoadMigrations(%s): %v", dialect, err)
+		}
+		// 各方言的迁移版本必须一一对应
+		if len(migrations) != len(reference) {
+			t.Errorf("%s has %d migrations; want %d", dialect, len(migrations), len(reference))
+			continue
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s migration %d has version %d; versions must be sequential", dialect, i, m.Version)
+			}
+			if m.Name != reference[i].Name {
+				t.Errorf("%s migration %04d is %q; want %q", dialect, m.Version, m.Name, reference[i].Name)
+			}
+			if len(splitStatements(m.Up)) == 0 || len(splitStatements(m.Down)) == 0 {
+				t.Errorf("%s migration %04d_%s has an empty up or down script", dialect, m.Version, m.Name)
+			}
+		}
+	}
+
+	if _, err := LoadMigrations("oracle"); err == nil {
+		t.Error("expected error for unknown dialect")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- comment
+CREATE TABLE a (
+    id INT
+);
+
+-- another comment
+DROP TABLE b;
+SELECT 1`
+
+	got := splitStatements(script)
+	want := []string{"CREATE TABLE a (\n    id INT\n)", "DROP TABLE b", "SELECT 1"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d statements %q; want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+
+	applied, err := MigrateUp(db)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(applied) == 0 {
+		t.Fatal("no migrations applied")
+	}
+
+	// 迁移出的表结构必须覆盖模型的全部字段
+	for _, model := range []interface{}{
+		&models.Product{}, &models.Order{}, &models.OrderItem{}, &models.Reservation{},
+		&models.UserProfile{}, &models.AccessRule{}, &models.OutboxEvent{}, &models.AuditLog{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("table %s is missing column %s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+
+	reverted, err := MigrateDown(db, len(applied))
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(reverted) != len(applied) {
+		t.Errorf("reverted %d migrations; want %d", len(reverted), len(applied))
+	}
+	if db.Migrator().HasTable(&models.Product{}) {
+		t.Error("products table still exists after full rollback")
+	}
+}
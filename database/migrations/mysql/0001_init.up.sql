@@ -0,0 +1,79 @@
+-- 初始表结构，使用IF NOT EXISTS以便接管已由AutoMigrate创建的数据库
+
+CREATE TABLE IF NOT EXISTS products (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    deleted_at DATETIME(3) NULL,
+    name VARCHAR(255) NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    stock INT NOT NULL DEFAULT 0,
+    start_time DATETIME NOT NULL,
+    end_time DATETIME NOT NULL,
+    seckill_stock INT NOT NULL DEFAULT 0,
+    price_mode VARCHAR(20) NOT NULL DEFAULT 'fixed',
+    floor_price DECIMAL(10,2) NOT NULL DEFAULT 0,
+    price_step DECIMAL(10,2) NOT NULL DEFAULT 0,
+    step_interval INT NOT NULL DEFAULT 0,
+    require_reservation TINYINT(1) NOT NULL DEFAULT 0,
+    eligibility_rules TEXT,
+    INDEX idx_products_deleted_at (deleted_at),
+    INDEX idx_products_start_time (start_time),
+    INDEX idx_products_end_time (end_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    order_no VARCHAR(64) NOT NULL,
+    user_id VARCHAR(64) NOT NULL,
+    product_id BIGINT UNSIGNED NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    UNIQUE INDEX idx_orders_order_no (order_no),
+    INDEX idx_orders_user_id (user_id),
+    INDEX idx_orders_product_id (product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    product_id BIGINT UNSIGNED NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    price DECIMAL(10,2) NOT NULL,
+    quantity INT NOT NULL DEFAULT 1,
+    INDEX idx_order_items_order_id (order_id),
+    INDEX idx_order_items_product_id (product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    user_id VARCHAR(64) NOT NULL,
+    product_id BIGINT UNSIGNED NOT NULL,
+    UNIQUE INDEX idx_reservation_user_product (user_id, product_id),
+    INDEX idx_reservations_product_id (product_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+    user_id VARCHAR(64) NOT NULL PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    tier VARCHAR(32) NOT NULL DEFAULT '',
+    registered_at DATETIME(3) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS access_rules (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    list VARCHAR(10) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    value VARCHAR(64) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at DATETIME(3) NULL,
+    UNIQUE INDEX idx_access_rule (list, kind, value)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
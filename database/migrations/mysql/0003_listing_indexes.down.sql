@@ -0,0 +1,8 @@
+ALTER TABLE orders
+    ADD INDEX idx_orders_user_id (user_id),
+    DROP INDEX idx_orders_user_status_created,
+    DROP INDEX idx_orders_user_created;
+
+ALTER TABLE products
+    ADD INDEX idx_products_start_time (start_time),
+    DROP INDEX idx_products_window;
@@ -0,0 +1,8 @@
+ALTER TABLE products
+    ADD INDEX idx_products_window (start_time, end_time),
+    DROP INDEX idx_products_start_time;
+
+ALTER TABLE orders
+    ADD INDEX idx_orders_user_created (user_id, created_at),
+    ADD INDEX idx_orders_user_status_created (user_id, status, created_at),
+    DROP INDEX idx_orders_user_id;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME(3) NULL,
+    actor VARCHAR(64) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target_type VARCHAR(32) NOT NULL DEFAULT '',
+    target_id VARCHAR(64) NOT NULL DEFAULT '',
+    `before` TEXT NULL,
+    `after` TEXT NULL,
+    diff TEXT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL DEFAULT '',
+    path VARCHAR(255) NOT NULL DEFAULT '',
+    status_code BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_audit_logs_created_at (created_at),
+    INDEX idx_audit_logs_actor (actor),
+    INDEX idx_audit_logs_action (action),
+    INDEX idx_audit_target (target_type, target_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
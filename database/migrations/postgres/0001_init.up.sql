@@ -0,0 +1,79 @@
+-- 初始表结构，使用IF NOT EXISTS以便接管已由AutoMigrate创建的数据库
+
+CREATE TABLE IF NOT EXISTS products (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    deleted_at TIMESTAMPTZ NULL,
+    name VARCHAR(255) NOT NULL,
+    price NUMERIC(10,2) NOT NULL,
+    stock INTEGER NOT NULL DEFAULT 0,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    seckill_stock INTEGER NOT NULL DEFAULT 0,
+    price_mode VARCHAR(20) NOT NULL DEFAULT 'fixed',
+    floor_price NUMERIC(10,2) NOT NULL DEFAULT 0,
+    price_step NUMERIC(10,2) NOT NULL DEFAULT 0,
+    step_interval INTEGER NOT NULL DEFAULT 0,
+    require_reservation BOOLEAN NOT NULL DEFAULT FALSE,
+    eligibility_rules TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_products_start_time ON products (start_time);
+CREATE INDEX IF NOT EXISTS idx_products_end_time ON products (end_time);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    order_no VARCHAR(64) NOT NULL,
+    user_id VARCHAR(64) NOT NULL,
+    product_id BIGINT NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    price NUMERIC(10,2) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_orders_order_no ON orders (order_no);
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_orders_product_id ON orders (product_id);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    order_id BIGINT NOT NULL,
+    product_id BIGINT NOT NULL,
+    product_name VARCHAR(255) NOT NULL,
+    price NUMERIC(10,2) NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1
+);
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items (order_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_product_id ON order_items (product_id);
+
+CREATE TABLE IF NOT EXISTS reservations (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    user_id VARCHAR(64) NOT NULL,
+    product_id BIGINT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservation_user_product ON reservations (user_id, product_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_product_id ON reservations (product_id);
+
+CREATE TABLE IF NOT EXISTS user_profiles (
+    user_id VARCHAR(64) NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    tier VARCHAR(32) NOT NULL DEFAULT '',
+    registered_at TIMESTAMPTZ NULL
+);
+
+CREATE TABLE IF NOT EXISTS access_rules (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NULL,
+    updated_at TIMESTAMPTZ NULL,
+    list VARCHAR(10) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    value VARCHAR(64) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_access_rule ON access_rules (list, kind, value);
@@ -0,0 +1 @@
+ALTER TABLE products ADD COLUMN inventory_group VARCHAR(64) NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS access_rules;
+DROP TABLE IF EXISTS user_profiles;
+DROP TABLE IF EXISTS reservations;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS products;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_orders_user_id ON orders (user_id);
+DROP INDEX IF EXISTS idx_orders_user_status_created;
+DROP INDEX IF EXISTS idx_orders_user_created;
+
+CREATE INDEX IF NOT EXISTS idx_products_start_time ON products (start_time);
+DROP INDEX IF EXISTS idx_products_window;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_products_window ON products (start_time, end_time);
+DROP INDEX IF EXISTS idx_products_start_time;
+
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_orders_user_status_created ON orders (user_id, status, created_at);
+DROP INDEX IF EXISTS idx_orders_user_id;
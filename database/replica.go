@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-seckill/config"
+
+	"gorm.io/gorm"
+)
+
+// Replicas 只读从库连接
+var Replicas []*gorm.DB
+
+var (
+	replicaCursor uint64
+	replicaMaxLag int64
+
+	replicaLagInterval time.Duration
+	replicaLagTimeout  time.Duration
+
+	// replicaStatuses 后台采样得到的最近一次从库状态，健康检查直接返回，不在请求中查询从库
+	replicaStatusMu sync.RWMutex
+	replicaStatuses []ReplicaHealth
+)
+
+// ReplicaHealth 从库健康状态
+type ReplicaHealth struct {
+	Name       string     `json:"name"`
+	Healthy    bool       `json:"healthy"`
+	LagSeconds *int64     `json:"lag_seconds"`
+	Error      string     `json:"error,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at,omitempty"`
+}
+
+// lagFunc 查询单个从库的复制延迟
+type lagFunc func(ctx context.Context, db *gorm.DB) (*int64, error)
+
+func initReplicas(cfg *config.Config) error {
+	Replicas = nil
+	replicaMaxLag = int64(cfg.Database.ReplicaMaxLag)
+	replicaLagInterval = time.Duration(cfg.Database.ReplicaLagInterval) * time.Second
+	replicaLagTimeout = time.Duration(cfg.Database.ReplicaLagTimeout) * time.Second
+	for i, dsn := range cfg.Database.ReplicaDSNs {
+		dialector, err := newDialector(cfg.Database.Driver, dsn)
+		if err != nil {
+			return err
+		}
+		db, err := openDB(cfg, dialector)
+		if err != nil {
+			return fmt.Errorf("replica-%d: %w", i, err)
+		}
+		Replicas = append(Replicas, db)
+	}
+	if len(Replicas) > 0 {
+		log.Printf("Database replicas connected: %d", len(Replicas))
+	}
+
+	// 采样前的状态视为不健康
+	statuses := make([]ReplicaHealth, len(Replicas))
+	for i := range statuses {
+		statuses[i] = ReplicaHealth{Name: fmt.Sprintf("replica-%d", i), Error: "replication lag not sampled yet"}
+	}
+	setReplicaStatuses(statuses)
+	return nil
+}
+
+// Reader 轮询选择一个从库，未配置从库时返回主库
+func Reader() *gorm.DB {
+	return pickReplica(DB, Replicas)
+}
+
+func pickReplica(primary *gorm.DB, replicas []*gorm.DB) *gorm.DB {
+	if len(replicas) == 0 {
+		return primary
+	}
+	n := atomic.AddUint64(&replicaCursor, 1)
+	return replicas[n%uint64(len(replicas))]
+}
+
+// StartReplicaMonitor 启动后台采样，立即采样一次，之后每 DB_REPLICA_LAG_INTERVAL 秒采样一次，ctx结束后停止
+// 间隔不大于0时不采样，从库状态保持为未采样
+func StartReplicaMonitor(ctx context.Context) {
+	if len(Replicas) == 0 || replicaLagInterval <= 0 {
+		return
+	}
+	replicas := Replicas
+	setReplicaStatuses(sampleReplicas(ctx, replicas, replicationLag, replicaLagTimeout))
+	go func() {
+		ticker := time.NewTicker(replicaLagInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				setReplicaStatuses(sampleReplicas(ctx, replicas, replicationLag, replicaLagTimeout))
+			}
+		}
+	}()
+}
+
+// ReplicaStatus 返回最近一次采样的从库连通性和复制延迟
+func ReplicaStatus() []ReplicaHealth {
+	replicaStatusMu.RLock()
+	defer replicaStatusMu.RUnlock()
+	return append([]ReplicaHealth(nil), replicaStatuses...)
+}
+
+func setReplicaStatuses(statuses []ReplicaHealth) {
+	replicaStatusMu.Lock()
+	defer replicaStatusMu.Unlock()
+	replicaStatuses = statuses
+}
+
+// sampleReplicas 并发查询所有从库的复制延迟，每个查询最多等待timeout
+func sampleReplicas(ctx context.Context, replicas []*gorm.DB, lag lagFunc, timeout time.Duration) []ReplicaHealth {
+	statuses := make([]ReplicaHealth, len(replicas))
+	var wg sync.WaitGroup
+	for i, db := range replicas {
+		wg.Add(1)
+		go func(i int, db *gorm.DB) {
+			defer wg.Done()
+			queryCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			status := ReplicaHealth{Name: fmt.Sprintf("replica-%d", i)}
+			seconds, err := lag(queryCtx, db)
+			if err == nil {
+				err = queryCtx.Err()
+			}
+			if err != nil {
+				status.Error = err.Error()
+			} else {
+				status.LagSeconds = seconds
+				status.Healthy = seconds != nil && *seconds <= replicaMaxLag
+			}
+			now := time.Now()
+			status.CheckedAt = &now
+			statuses[i] = status
+		}(i, db)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// replicationLag 查询从库的复制延迟，复制未运行时返回nil
+func replicationLag(ctx context.Context, db *gorm.DB) (*int64, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		return postgresLag(ctx, sqlDB)
+	case DriverMySQL:
+		lag, err := queryLag(ctx, sqlDB, "SHOW REPLICA STATUS", "Seconds_Behind_Source")
+		if err != nil {
+			// MySQL 8.0.22 之前的版本
+			lag, err = queryLag(ctx, sqlDB, "SHOW SLAVE STATUS", "Seconds_Behind_Master")
+		}
+		return lag, err
+	default:
+		return nil, fmt.Errorf("replication lag is not supported on %s", db.Dialector.Name())
+	}
+}
+
+// postgresLag 以最后一次回放事务的时间估算PostgreSQL备库延迟
+func postgresLag(ctx context.Context, sqlDB *sql.DB) (*int64, error) {
+	var inRecovery bool
+	var lag sql.NullInt64
+	err := sqlDB.QueryRowContext(ctx, `SELECT pg_is_in_recovery(),
+		EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())::bigint`).Scan(&inRecovery, &lag)
+	if err != nil {
+		return nil, err
+	}
+	if !inRecovery {
+		return nil, fmt.Errorf("not configured as a replica")
+	}
+	if !lag.Valid {
+		return nil, nil
+	}
+	return &lag.Int64, nil
+}
+
+func queryLag(ctx context.Context, sqlDB *sql.DB, query, column string) (*int64, error) {
+	rows, err := sqlDB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("not configured as a replica")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	for i, name := range columns {
+		if name != column {
+			continue
+		}
+		if values[i] == nil {
+			return nil, nil
+		}
+		lag, err := strconv.ParseInt(string(values[i]), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &lag, nil
+	}
+	return nil, fmt.Errorf("column %s not found", column)
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestPickReplica(t *testing.T) {
+	primary := &gorm.DB{}
+	if got := pickReplica(primary, nil); got != primary {
+		t.Fatal("expected primary when no replicas are configured")
+	}
+
+	replicas := []*gorm.DB{{}, {}}
+	seen := make(map[*gorm.DB]int)
+	for i := 0; i < 4; i++ {
+		seen[pickReplica(primary, replicas)]++
+	}
+	if seen[primary] != 0 {
+		t.Fatal("reads must not go to the primary when replicas are configured")
+	}
+	if seen[replicas[0]] != 2 || seen[replicas[1]] != 2 {
+		t.Fatalf("expected round-robin across replicas, got %v", seen)
+	}
+}
+
+func TestSampleReplicasTimesOutSlowQueries(t *testing.T) {
+	replicaMaxLag = 10
+	fast, slow, lagging := &gorm.DB{}, &gorm.DB{}, &gorm.DB{}
+	lag := func(ctx context.Context, db *gorm.DB) (*int64, error) {
+		seconds := int64(1)
+		switch db {
+		case slow:
+			// 从库无响应时查询阻塞到超时
+			<-ctx.Done()
+			return nil, ctx.Err()
+		case lagging:
+			seconds = 30
+		}
+		return &seconds, nil
+	}
+
+	start := time.Now()
+	statuses := sampleReplicas(context.Background(), []*gorm.DB{fast, slow, lagging}, lag, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("sampling took %v; want it bounded by the query timeout", elapsed)
+	}
+	if !statuses[0].Healthy || *statuses[0].LagSeconds != 1 || statuses[0].CheckedAt == nil {
+		t.Errorf("fast replica = %+v; want healthy with lag 1", statuses[0])
+	}
+	if statuses[1].Healthy || statuses[1].Error == "" {
+		t.Errorf("slow replica = %+v; want unhealthy with a timeout error", statuses[1])
+	}
+	if statuses[2].Healthy || *statuses[2].LagSeconds != 30 {
+		t.Errorf("lagging replica = %+v; want unhealthy with lag 30", statuses[2])
+	}
+
+	// 健康检查读取缓存的采样结果，不查询从库
+	setReplicaStatuses(statuses)
+	if cached := ReplicaStatus(); len(cached) != 3 || cached[1].Error != statuses[1].Error {
+		t.Errorf("ReplicaStatus = %+v; want the sampled statuses", cached)
+	}
+}
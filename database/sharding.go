@@ -0,0 +1,180 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"go-seckill/models"
+	"go-seckill/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EnsureOrderShards 以 orders / order_items 为模板创建订单分片表，分片数为1时不做处理
+func EnsureOrderShards(db *gorm.DB, shards int) error {
+	if shards < 1 || shards > utils.MaxOrderShards {
+		return fmt.Errorf("order shards must be between 1 and %d, got %d", utils.MaxOrderShards, shards)
+	}
+	if shards == 1 {
+		return nil
+	}
+
+	for shard := 0; shard < shards; shard++ {
+		for template, table := range map[string]string{
+			"orders":      utils.OrderTable(shard, shards),
+			"order_items": utils.OrderItemTable(shard, shards),
+		} {
+			stmts, err := shardTableDDL(db, template, table)
+			if err != nil {
+				return err
+			}
+			for _, stmt := range stmts {
+				if err := db.Exec(stmt).Error; err != nil {
+					return fmt.Errorf("failed to create shard table %s: %w", table, err)
+				}
+			}
+		}
+	}
+	log.Printf("Order shard tables ready: %d", shards)
+	return nil
+}
+
+// shardTableDDL 按数据库方言生成以template为模板创建分片表的语句
+func shardTableDDL(db *gorm.DB, template, table string) ([]string, error) {
+	switch db.Dialector.Name() {
+	case DriverMySQL:
+		return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", table, template)}, nil
+	case DriverPostgres:
+		// INCLUDING ALL 复制列默认值、约束和索引，分片表与模板表共用ID序列
+		return []string{fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE %s INCLUDING ALL)", table, template)}, nil
+	case DriverSQLite:
+		return sqliteShardDDL(db, template, table)
+	default:
+		return nil, fmt.Errorf("order sharding is not supported on %s", db.Dialector.Name())
+	}
+}
+
+var (
+	sqliteCreateTable = regexp.MustCompile("(?is)^CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?[`\"]?\\w+[`\"]?")
+	sqliteCreateIndex = regexp.MustCompile("(?is)^CREATE\\s+(UNIQUE\\s+)?INDEX\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?[`\"]?(\\w+)[`\"]?\\s+ON\\s+[`\"]?\\w+[`\"]?")
+)
+
+// sqliteShardDDL SQLite不支持 CREATE TABLE ... LIKE，改为复制模板表和索引的建表语句。
+// SQLite的索引名在整个库中唯一，分片表的索引名中的模板表名替换为分片表名
+func sqliteShardDDL(db *gorm.DB, template, table string) ([]string, error) {
+	var rows []struct {
+		Type string
+		Name string
+		SQL  string
+	}
+	err := db.Raw("SELECT type, name, sql FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL ORDER BY type = 'index'", template).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("template table %s does not exist", template)
+	}
+
+	stmts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		switch row.Type {
+		case "table":
+			stmts = append(stmts, sqliteCreateTable.ReplaceAllLiteralString(row.SQL, "CREATE TABLE IF NOT EXISTS "+table))
+		case "index":
+			name := strings.Replace(row.Name, template, table, 1)
+			if name == row.Name {
+				name = row.Name + "_" + table
+			}
+			m := sqliteCreateIndex.FindStringSubmatch(row.SQL)
+			if m == nil {
+				return nil, fmt.Errorf("unrecognized index definition %s", row.Name)
+			}
+			stmts = append(stmts, fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s%s", m[1], name, table, row.SQL[len(m[0]):]))
+		}
+	}
+	return stmts, nil
+}
+
+// rebalanceBatch 重新分片时每批读取的订单数
+const rebalanceBatch = 500
+
+// RebalanceOrderShards 调整分片数后把订单迁移到用户所在的新分片，返回迁移的订单数。
+// 依次扫描未分片的 orders 表和所有已存在的分片表，每个订单连同明细在一个事务中迁移；
+// 迁移后订单在新表中的自增ID会变化，订单号不变。需要先用新的分片数执行 EnsureOrderShards
+func RebalanceOrderShards(db *gorm.DB, shards int) (int, error) {
+	if shards < 1 || shards > utils.MaxOrderShards {
+		return 0, fmt.Errorf("order shards must be between 1 and %d, got %d", utils.MaxOrderShards, shards)
+	}
+
+	sources := [][2]string{{"orders", "order_items"}}
+	for shard := 0; shard < utils.MaxOrderShards; shard++ {
+		table := utils.OrderTable(shard, utils.MaxOrderShards)
+		if db.Migrator().HasTable(table) {
+			sources = append(sources, [2]string{table, utils.OrderItemTable(shard, utils.MaxOrderShards)})
+		}
+	}
+
+	moved := 0
+	for _, source := range sources {
+		var last uint
+		for {
+			var batch []models.Order
+			err := db.Table(source[0]).Where("id > ?", last).Order("id").Limit(rebalanceBatch).Find(&batch).Error
+			if err != nil {
+				return moved, err
+			}
+			for i := range batch {
+				order := &batch[i]
+				last = order.ID
+				shard := utils.OrderShard(order.UserID, shards)
+				target := [2]string{utils.OrderTable(shard, shards), utils.OrderItemTable(shard, shards)}
+				if target == source {
+					continue
+				}
+				if err := moveOrder(db, order, source, target); err != nil {
+					return moved, fmt.Errorf("failed to move order %s to %s: %w", order.OrderNo, target[0], err)
+				}
+				moved++
+			}
+			if len(batch) < rebalanceBatch {
+				break
+			}
+		}
+	}
+	log.Printf("Order shards rebalanced to %d: %d orders moved", shards, moved)
+	return moved, nil
+}
+
+// moveOrder 把订单及其明细从source表迁移到target表
+func moveOrder(db *gorm.DB, order *models.Order, source, target [2]string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		oldID := order.ID
+		var items []models.OrderItem
+		if err := tx.Table(source[1]).Where("order_id = ?", oldID).Order("id").Find(&items).Error; err != nil {
+			return err
+		}
+
+		order.ID = 0
+		if err := tx.Table(target[0]).Omit(clause.Associations).Create(order).Error; err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			for i := range items {
+				items[i].ID = 0
+				items[i].OrderID = order.ID
+			}
+			if err := tx.Table(target[1]).Create(&items).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Table(source[1]).Where("order_id = ?", oldID).Delete(&models.OrderItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Table(source[0]).Where("id = ?", oldID).Delete(&models.Order{}).Error
+	})
+}
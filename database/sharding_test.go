@@ -0,0 +1,186 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-seckill/models"
+	"go-seckill/repository"
+	"go-seckill/utils"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openMigratedSQLite(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	return db
+}
+
+func TestEnsureOrderShardsSQLite(t *testing.T) {
+	db := openMigratedSQLite(t)
+	const shards = 4
+	for i := 0; i < 2; i++ {
+		if err := EnsureOrderShards(db, shards); err != nil {
+			t.Fatalf("EnsureOrderShards (run %d): %v", i+1, err)
+		}
+	}
+	for shard := 0; shard < shards; shard++ {
+		for _, table := range []string{utils.OrderTable(shard, shards), utils.OrderItemTable(shard, shards)} {
+			if !db.Migrator().HasTable(table) {
+				t.Errorf("shard table %s was not created", table)
+			}
+		}
+		if !db.Migrator().HasIndex(utils.OrderTable(shard, shards), "idx_"+utils.OrderTable(shard, shards)+"_order_no") {
+			t.Errorf("shard table %s is missing the order_no index", utils.OrderTable(shard, shards))
+		}
+	}
+
+	// 分片表可以正常写入和查询，订单号在分片表内唯一
+	repos := repository.NewGormRepositories(db, nil, shards)
+	for _, userID := range []string{"u1", "u2", "u3", "u4", "u5"} {
+		shard := utils.OrderShard(userID, shards)
+		order := &models.Order{
+			OrderNo:     utils.GenerateOrderNo(shard, shards),
+			UserID:      userID,
+			ProductID:   1,
+			ProductName: "p",
+			Price:       1,
+			Status:      models.OrderStatusPending,
+			Items:       []models.OrderItem{{ProductID: 2, ProductName: "q", Price: 1, Quantity: 1}},
+		}
+		if err := repos.Orders.Create(order); err != nil {
+			t.Fatalf("Create(%s): %v", userID, err)
+		}
+		found, err := repos.Orders.FindByOrderNo(order.OrderNo)
+		if err != nil || found.UserID != userID || len(found.Items) != 1 {
+			t.Errorf("FindByOrderNo(%s) = %+v, %v", order.OrderNo, found, err)
+		}
+		if count, err := repos.Orders.CountActiveByUserProduct(userID, 2); err != nil || count != 1 {
+			t.Errorf("CountActiveByUserProduct(%s) = %d, %v; want 1", userID, count, err)
+		}
+		if err := db.Table(utils.OrderTable(shard, shards)).Create(&models.Order{
+			OrderNo: order.OrderNo, UserID: userID, ProductName: "p", CreatedAt: time.Now(),
+		}).Error; err == nil {
+			t.Errorf("duplicate order number %s accepted by %s", order.OrderNo, utils.OrderTable(shard, shards))
+		}
+	}
+	orders, err := repos.Orders.Query(repository.OrderQuery{ProductID: 1})
+	if err != nil || len(orders) != 5 {
+		t.Errorf("Query = %d orders, %v; want 5", len(orders), err)
+	}
+}
+
+func TestRebalanceOrderShardsSQLite(t *testing.T) {
+	db := openMigratedSQLite(t)
+
+	// 分片前的旧格式订单写在 orders 表中
+	single := repository.NewGormRepositories(db, nil, 1)
+	createdAt := time.Now().Truncate(time.Second)
+	var orderNos []string
+	for i := 0; i < 12; i++ {
+		orderNo := fmt.Sprintf("ORD%d%08d", createdAt.Unix(), i)
+		order := &models.Order{
+			OrderNo: orderNo, UserID: fmt.Sprintf("u%d", i), ProductID: 1, ProductName: "p", Price: 1,
+			Status: models.OrderStatusPending, CreatedAt: createdAt,
+			Items: []models.OrderItem{{ProductID: 2, ProductName: "q", Price: 1, Quantity: 1}},
+		}
+		if err := single.Orders.Create(order); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		orderNos = append(orderNos, orderNo)
+	}
+
+	// 开启分片后重新分片前，旧订单号仍能从 orders 表中找到
+	const shards = 4
+	if err := EnsureOrderShards(db, shards); err != nil {
+		t.Fatalf("EnsureOrderShards: %v", err)
+	}
+	sharded := repository.NewGormRepositories(db, nil, shards)
+	if _, err := sharded.Orders.FindByOrderNo(orderNos[0]); err != nil {
+		t.Errorf("FindByOrderNo before rebalance: %v", err)
+	}
+	// 计数同样包含 orders 表中的旧订单，限购检查不会放过重新分片前已购买的用户
+	if count, err := sharded.Orders.CountActiveByUserProduct("u0", 2); err != nil || count != 1 {
+		t.Errorf("CountActiveByUserProduct before rebalance = %d, %v; want 1", count, err)
+	}
+	if count, err := sharded.Orders.CountActiveByUser("u0", time.Time{}); err != nil || count != 1 {
+		t.Errorf("CountActiveByUser before rebalance = %d, %v; want 1", count, err)
+	}
+	if count, err := sharded.Orders.CountActiveByProduct(1); err != nil || count != int64(len(orderNos)) {
+		t.Errorf("CountActiveByProduct before rebalance = %d, %v; want %d", count, err, len(orderNos))
+	}
+	if _, err := sharded.Orders.FindByOrderNo("bogus"); !errors.Is(err, repository.ErrUnroutableOrder) {
+		t.Errorf("FindByOrderNo(bogus) err = %v; want ErrUnroutableOrder", err)
+	}
+	if err := sharded.Orders.UpdateStatus("bogus", models.OrderStatusPaid); !errors.Is(err, repository.ErrUnroutableOrder) {
+		t.Errorf("UpdateStatus(bogus) err = %v; want ErrUnroutableOrder", err)
+	}
+
+	moved, err := RebalanceOrderShards(db, shards)
+	if err != nil || moved != len(orderNos) {
+		t.Fatalf("RebalanceOrderShards = %d, %v; want %d", moved, err, len(orderNos))
+	}
+	if moved, err := RebalanceOrderShards(db, shards); err != nil || moved != 0 {
+		t.Errorf("second RebalanceOrderShards = %d, %v; want 0", moved, err)
+	}
+
+	for i, orderNo := range orderNos {
+		userID := fmt.Sprintf("u%d", i)
+		if err := sharded.Orders.UpdateStatus(orderNo, models.OrderStatusPaid); err != nil {
+			t.Fatalf("UpdateStatus(%s): %v", orderNo, err)
+		}
+		found, err := sharded.Orders.FindByOrderNo(orderNo)
+		if err != nil || found.Status != models.OrderStatusPaid || len(found.Items) != 1 {
+			t.Errorf("FindByOrderNo(%s) = %+v, %v", orderNo, found, err)
+		}
+		if count, err := sharded.Orders.CountActiveByUserProduct(userID, 2); err != nil || count != 1 {
+			t.Errorf("CountActiveByUserProduct(%s) = %d, %v; want 1", userID, count, err)
+		}
+	}
+	for _, productID := range []uint{1, 2} {
+		if count, err := sharded.Orders.CountActiveByProduct(productID); err != nil || count != int64(len(orderNos)) {
+			t.Errorf("CountActiveByProduct(%d) after rebalance = %d, %v; want %d", productID, count, err, len(orderNos))
+		}
+	}
+	// 明细中的商品同样可以查到订单
+	if orders, err := sharded.Orders.Query(repository.OrderQuery{ProductID: 2}); err != nil || len(orders) != len(orderNos) {
+		t.Errorf("Query(ProductID: 2) = %d orders, %v; want %d", len(orders), err, len(orderNos))
+	}
+
+	// 所有订单创建时间相同，分布在多个分片，游标中的分片号保证逐页读取不重复不遗漏
+	seen := make(map[string]bool)
+	var before *repository.OrderCursor
+	for page := 0; page <= len(orderNos); page++ {
+		orders, err := sharded.Orders.Query(repository.OrderQuery{ProductID: 1, Before: before, Limit: 5})
+		if err != nil {
+			t.Fatalf("Query: %v", err)
+		}
+		for _, order := range orders {
+			if seen[order.OrderNo] {
+				t.Errorf("order %s returned twice", order.OrderNo)
+			}
+			seen[order.OrderNo] = true
+		}
+		if len(orders) < 5 {
+			break
+		}
+		before = repository.NewOrderCursor(&orders[len(orders)-1], shards)
+	}
+	if len(seen) != len(orderNos) {
+		t.Errorf("paged through %d orders; want %d", len(seen), len(orderNos))
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go-seckill/cache"
 	"go-seckill/config"
 	"go-seckill/controller"
 	"go-seckill/database"
+	"go-seckill/outbox"
+	"go-seckill/repository"
 	"go-seckill/router"
 	"go-seckill/service"
 )
@@ -16,11 +22,38 @@ func main() {
 	// 加载配置
 	cfg := config.Load()
 
+	// 迁移子命令：migrate up|down [N]|status|rebalance
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg, os.Args[2:])
+		return
+	}
+	// 库存键迁移子命令：把旧版本的库存键和库存流水迁移到带商品哈希标签的新键
+	if len(os.Args) > 1 && os.Args[1] == "migrate-keys" {
+		runMigrateKeys(cfg)
+		return
+	}
+
+	// 未配置身份认证密钥时拒绝启动，开发环境需显式设置 AUTH_ALLOW_UNAUTHENTICATED=true
+	if (cfg.Auth.UserSecret == "" || cfg.Auth.AdminSecret == "") && !cfg.Auth.AllowUnauthenticated {
+		log.Fatalf("AUTH_USER_SECRET and AUTH_ADMIN_SECRET must be set (set AUTH_ALLOW_UNAUTHENTICATED=true to run without authentication in development)")
+	}
+	if cfg.Auth.UserSecret == "" {
+		log.Printf("AUTH_USER_SECRET is not set: seckill requests are not authenticated and user blocks can be bypassed")
+	}
+	if cfg.Auth.AdminSecret == "" {
+		log.Printf("AUTH_ADMIN_SECRET is not set: admin requests are not authenticated and audit actors are recorded as unverified")
+	}
+
 	// 初始化数据库
 	if err := database.InitDB(cfg); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	// 创建订单分片表
+	if err := database.EnsureOrderShards(database.DB, cfg.Database.OrderShards); err != nil {
+		log.Fatalf("Failed to prepare order shards: %v", err)
+	}
+
 	// 初始化Redis
 	if err := cache.InitRedis(cfg); err != nil {
 		log.Fatalf("Failed to initialize Redis: %v", err)
@@ -30,13 +63,37 @@ func main() {
 	gin.SetMode(cfg.Server.Mode)
 
 	// 初始化服务
-	seckillService := service.NewSeckillService(cfg)
+	repos := repository.NewGormRepositories(database.DB, database.Reader, cfg.Database.OrderShards)
+	seckillService := service.NewSeckillService(cfg, repos)
+	accessListService := service.NewAccessListService(cfg, repos.AccessRules)
+	auditService := service.NewAuditService(repos.Audit)
+	if err := accessListService.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to load access list: %v", err)
+	}
+	// 后台采样从库复制延迟，健康检查返回采样结果
+	database.StartReplicaMonitor(context.Background())
+	// 订阅其他实例的商品缓存失效通知
+	seckillService.StartCacheSync(context.Background())
+	// 构建商品ID布隆过滤器并定期重建，失败时不拦截请求
+	if err := seckillService.BuildProductFilter(); err != nil {
+		log.Printf("Failed to build product bloom filter: %v", err)
+	}
+	seckillService.StartProductFilterRebuild(context.Background())
+
+	// 启动发件箱中继，向下游投递订单事件
+	publisher, err := outbox.NewPublisher(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create outbox publisher: %v", err)
+	}
+	outbox.NewRelay(cfg, repos.Outbox, publisher).Start(context.Background())
 
 	// 初始化控制器
 	seckillController := controller.NewSeckillController(seckillService)
+	accessController := controller.NewAccessController(accessListService)
+	auditController := controller.NewAuditController(auditService)
 
 	// 设置路由
-	r := router.SetupRouter(seckillController)
+	r := router.SetupRouter(cfg, seckillController, accessController, auditController, accessListService, auditService)
 
 	// 启动服务
 	addr := ":" + cfg.Server.Port
@@ -46,3 +103,96 @@ func main() {
 	}
 }
 
+// runMigrateKeys 迁移Redis中的库存键，新键已存在的旧键只打印不覆盖
+func runMigrateKeys(cfg *config.Config) {
+	if err := database.InitDB(cfg); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	if err := cache.InitRedis(cfg); err != nil {
+		log.Fatalf("Failed to initialize Redis: %v", err)
+	}
+
+	repos := repository.NewGormRepositories(database.DB, database.Reader, cfg.Database.OrderShards)
+	result, err := service.NewSeckillService(cfg, repos).MigrateInventoryKeys()
+	if result != nil {
+		for _, moved := range result.Moved {
+			fmt.Printf("moved    %s\n", moved)
+		}
+		for _, key := range result.Conflicts {
+			fmt.Printf("conflict %s (target exists, skipped)\n", key)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Migrate keys failed: %v", err)
+	}
+	fmt.Printf("%d keys moved, %d conflicts\n", len(result.Moved), len(result.Conflicts))
+}
+
+// runMigrate 执行版本化数据库迁移
+func runMigrate(cfg *config.Config, args []string) {
+	cfg.Database.AutoMigrate = false
+	if err := database.InitDB(cfg); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	if len(args) == 0 {
+		log.Fatal("Usage: seckill migrate up|down [N]|status|rebalance")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB)
+		for _, m := range applied {
+			fmt.Printf("applied  %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migrate up failed: %v", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		if err := database.EnsureOrderShards(database.DB, cfg.Database.OrderShards); err != nil {
+			log.Fatalf("Failed to prepare order shards: %v", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of steps: %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := database.MigrateDown(database.DB, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatalf("Migrate down failed: %v", err)
+		}
+	case "status":
+		states, err := database.MigrationStatus(database.DB)
+		if err != nil {
+			log.Fatalf("Migrate status failed: %v", err)
+		}
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", state.Version, state.Name, applied)
+		}
+	case "rebalance":
+		// 调整 DB_ORDER_SHARDS 后把历史订单迁移到用户所在的新分片
+		if err := database.EnsureOrderShards(database.DB, cfg.Database.OrderShards); err != nil {
+			log.Fatalf("Failed to prepare order shards: %v", err)
+		}
+		moved, err := database.RebalanceOrderShards(database.DB, cfg.Database.OrderShards)
+		fmt.Printf("moved %d orders\n", moved)
+		if err != nil {
+			log.Fatalf("Rebalance failed: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown migrate command %q, expected up|down|status|rebalance", args[0])
+	}
+}
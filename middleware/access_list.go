@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPeekBodySize 读取请求体中user_id时的最大字节数
+const maxPeekBodySize = 1 << 20
+
+// AccessChecker 黑白名单检查
+type AccessChecker interface {
+	Check(userID, ip string) (bool, string)
+}
+
+// AccessListMiddleware 黑白名单中间件，按用户和客户端IP检查
+// 经过AuthMiddleware认证时按认证的身份检查，请求体中的user_id必须与之一致，
+// 否则用户可以换一个user_id绕过用户黑名单；未启用认证时只能使用请求体中的user_id
+func AccessListMiddleware(checker AccessChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := peekUserID(c)
+		if principal := c.GetString(PrincipalKey); principal != "" {
+			if userID != "" && userID != principal {
+				c.JSON(http.StatusForbidden, gin.H{
+					"code": 403,
+					"msg":  "user_id does not match the authenticated user",
+				})
+				c.Abort()
+				return
+			}
+			userID = principal
+		}
+
+		allowed, reason := checker.Check(userID, c.ClientIP())
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  reason,
+			})
+			c.Abort()
+			return
+		}
+		// 供用户级限流使用
+		if userID != "" {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	}
+}
+
+// peekUserID 读取JSON请求体中的user_id，并还原请求体供后续处理
+func peekUserID(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekBodySize))
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var req struct {
+		UserID string `json:"user_id"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return ""
+	}
+	return req.UserID
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-seckill/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// blockUsers 只封禁指定用户的检查器
+type blockUsers map[string]bool
+
+func (b blockUsers) Check(userID, ip string) (bool, string) {
+	return !b[userID], "user blocked"
+}
+
+func TestAccessListUsesAuthenticatedUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "test-secret"
+	r := gin.New()
+	r.POST("/buy", AuthMiddleware(secret), AccessListMiddleware(blockUsers{"bad": true}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(token, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/buy", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	good := utils.SignIdentity(secret, "good", time.Now().Add(time.Hour))
+	bad := utils.SignIdentity(secret, "bad", time.Now().Add(time.Hour))
+	for _, tc := range []struct {
+		name, token, body string
+		want              int
+	}{
+		{"authenticated user", good, `{"user_id":"good"}`, http.StatusOK},
+		{"no token", "", `{"user_id":"good"}`, http.StatusUnauthorized},
+		{"token signed with another secret", utils.SignIdentity("other", "good", time.Now().Add(time.Hour)), `{"user_id":"good"}`, http.StatusUnauthorized},
+		{"expired token", utils.SignIdentity(secret, "good", time.Now().Add(-time.Second)), `{"user_id":"good"}`, http.StatusUnauthorized},
+		{"blocked user", bad, `{"user_id":"bad"}`, http.StatusForbidden},
+		{"blocked user claiming another user_id", bad, `{"user_id":"good"}`, http.StatusForbidden},
+		{"blocked user without user_id in body", bad, `{}`, http.StatusForbidden},
+	} {
+		if got := send(tc.token, tc.body); got != tc.want {
+			t.Errorf("%s: status = %d; want %d", tc.name, got, tc.want)
+		}
+	}
+}
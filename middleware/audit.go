@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"log"
+
+	"go-seckill/audit"
+	"go-seckill/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditActorHeader 操作人请求头，未配置管理员认证时作为未经验证的操作人记录
+const AuditActorHeader = "X-Admin-User"
+
+// unverifiedActorPrefix 未配置认证时的操作人前缀，请求头可以被任意伪造，不能作为可信的操作人
+const unverifiedActorPrefix = "unverified:"
+
+// unauthenticatedActor 认证失败的请求的操作人，请求头附在冒号之后
+const unauthenticatedActor = "unauthenticated"
+
+// AuditRecorder 审计日志写入
+type AuditRecorder interface {
+	Create(entry *models.AuditLog) error
+}
+
+// AuditMiddleware 管理接口审计中间件，记录所有请求（包括只读请求，无论成功与否，包括认证失败的请求）
+// 服务层通过 audit.Record 补充操作类型、对象和变更前后的快照
+// 需注册在 AuthMiddleware 之前，操作人在请求结束后取自认证通过的身份，认证失败的请求单独标记
+func AuditMiddleware(recorder AuditRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry := &models.AuditLog{
+			Action: c.Request.Method + " " + c.FullPath(),
+			IP:     c.ClientIP(),
+			Method: c.Request.Method,
+			Path:   c.Request.URL.Path,
+		}
+		c.Request = c.Request.WithContext(audit.NewContext(c.Request.Context(), entry))
+
+		c.Next()
+
+		entry.Actor = auditActor(c)
+		entry.StatusCode = c.Writer.Status()
+		if err := recorder.Create(entry); err != nil {
+			log.Printf("Failed to write audit log for %s %s: %v", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+// auditActor 操作人，优先取认证通过的身份；认证失败时记为 unauthenticated[:请求头]，
+// 未配置认证时记录带 unverified: 前缀的请求头
+func auditActor(c *gin.Context) string {
+	if principal := c.GetString(PrincipalKey); principal != "" {
+		return principal
+	}
+	header := c.GetHeader(AuditActorHeader)
+	if c.GetBool(AuthFailedKey) {
+		if header != "" {
+			return unauthenticatedActor + ":" + header
+		}
+		return unauthenticatedActor
+	}
+	if header != "" {
+		return unverifiedActorPrefix + header
+	}
+	return "unknown"
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-seckill/models"
+	"go-seckill/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditEntries 记录写入的审计日志
+type auditEntries []*models.AuditLog
+
+func (a *auditEntries) Create(entry *models.AuditLog) error {
+	*a = append(*a, entry)
+	return nil
+}
+
+func TestAuditActorComesFromAuthenticatedAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const secret = "admin-secret"
+
+	for _, tc := range []struct {
+		name, secret, token, header string
+		status                      int
+		actor                       string
+	}{
+		{"authenticated admin", secret, utils.SignIdentity(secret, "alice", time.Now().Add(time.Hour)), "mallory", http.StatusOK, "alice"},
+		{"forged header without token", secret, "", "alice", http.StatusUnauthorized, "unauthenticated:alice"},
+		{"invalid token", secret, "bogus", "", http.StatusUnauthorized, "unauthenticated"},
+		{"authentication disabled", "", "", "alice", http.StatusOK, "unverified:alice"},
+		{"no identity", "", "", "", http.StatusOK, "unknown"},
+	} {
+		var entries auditEntries
+		r := gin.New()
+		r.POST("/admin", AuditMiddleware(&entries), AuthMiddleware(tc.secret), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/admin", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		if tc.header != "" {
+			req.Header.Set(AuditActorHeader, tc.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: status = %d; want %d", tc.name, w.Code, tc.status)
+		}
+		if len(entries) != 1 || entries[0].Actor != tc.actor || entries[0].StatusCode != tc.status {
+			t.Errorf("%s: audit entries = %+v; want one entry by %q", tc.name, entries, tc.actor)
+		}
+	}
+}
+
+func TestAuditRecordsReadRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var entries auditEntries
+	r := gin.New()
+	r.Use(AuditMiddleware(&entries))
+	r.GET("/admin/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/orders", nil))
+	if len(entries) != 1 || entries[0].Action != "GET /admin/orders" || entries[0].StatusCode != http.StatusOK {
+		t.Errorf("audit entries = %+v; want the GET request recorded", entries)
+	}
+}
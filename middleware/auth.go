@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"go-seckill/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey 认证通过的身份在gin上下文中的键
+const PrincipalKey = "principal"
+
+// AuthFailedKey 认证失败时在gin上下文中设置的标记，供审计日志区分认证失败的请求
+const AuthFailedKey = "auth_failed"
+
+// AuthMiddleware 校验 Authorization: Bearer <身份令牌>，通过后把身份写入上下文
+// secret 为空时不认证，后续中间件只能使用请求中未经验证的身份
+func AuthMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		subject, err := utils.VerifyIdentity(secret, token, time.Now())
+		if err != nil {
+			c.Set(AuthFailedKey, true)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code": 401,
+				"msg":  "unauthorized",
+			})
+			c.Abort()
+			return
+		}
+		c.Set(PrincipalKey, subject)
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,32 +9,132 @@ import (
 
 // Product 商品模型
 type Product struct {
-	ID          uint      `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
-	Name        string    `gorm:"type:varchar(255);not null" json:"name"`
-	Price       float64   `gorm:"type:decimal(10,2);not null" json:"price"`
-	Stock       int       `gorm:"type:int;not null;default:0" json:"stock"`
-	StartTime   time.Time `gorm:"type:datetime;not null" json:"start_time"`
-	EndTime     time.Time `gorm:"type:datetime;not null" json:"end_time"`
-	SeckillStock int     `gorm:"type:int;not null;default:0" json:"seckill_stock"`
+	ID           uint           `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	Name         string         `gorm:"type:varchar(255);not null" json:"name"`
+	Price        float64        `gorm:"type:decimal(10,2);not null" json:"price"`
+	Stock        int            `gorm:"type:int;not null;default:0" json:"stock"`
+	StartTime    time.Time      `gorm:"not null;index:idx_products_window,priority:1" json:"start_time"`
+	EndTime      time.Time      `gorm:"not null;index;index:idx_products_window,priority:2" json:"end_time"`
+	SeckillStock int            `gorm:"type:int;not null;default:0" json:"seckill_stock"`
+
+	// 降价拍（荷兰式拍卖）参数：价格从Price开始，每StepInterval秒下降PriceStep，直至FloorPrice
+	PriceMode    string  `gorm:"type:varchar(20);not null;default:'fixed'" json:"price_mode"`
+	FloorPrice   float64 `gorm:"type:decimal(10,2);not null;default:0" json:"floor_price"`
+	PriceStep    float64 `gorm:"type:decimal(10,2);not null;default:0" json:"price_step"`
+	StepInterval int     `gorm:"type:int;not null;default:0" json:"step_interval"`
+
+	// RequireReservation 为true时只有预约过的用户才能获取秒杀令牌
+	RequireReservation bool `gorm:"not null;default:false" json:"require_reservation"`
+
+	// InventoryGroup 库存分组，只能在创建时指定。同组商品的库存键使用同一个哈希标签，
+	// 落在Redis Cluster的同一个槽，只有同组商品才能组合购；为空时每个商品单独一组
+	InventoryGroup string `gorm:"type:varchar(64);not null;default:''" json:"inventory_group,omitempty"`
+
+	// EligibilityRules 购买资格规则配置，以JSON形式存储
+	EligibilityRules []RuleConfig `gorm:"type:text;serializer:json" json:"eligibility_rules,omitempty"`
+
+	// 以下字段由服务端实时计算，不落库
+	CurrentPrice    float64    `gorm:"-" json:"current_price"`
+	NextPriceDropAt *time.Time `gorm:"-" json:"next_price_drop_at,omitempty"`
+	LiveStock       *LiveStock `gorm:"-" json:"live_stock,omitempty"`
+}
+
+// LiveStock Redis中的实时剩余库存，由服务端查询时计算
+type LiveStock struct {
+	ProductID uint  `json:"product_id"`
+	Remaining int64 `json:"remaining"`
+	SoldOut   bool  `json:"sold_out"`
+	// RemainingPercent 剩余库存占秒杀库存的百分比，保留两位小数
+	RemainingPercent float64 `json:"remaining_percent"`
 }
 
+// PriceMode 定价模式常量
+const (
+	PriceModeFixed = "fixed"
+	PriceModeDutch = "dutch"
+)
+
+// ProductStatus 商品销售状态常量，由当前时间和秒杀时间段计算
+const (
+	ProductStatusUpcoming = "upcoming"
+	ProductStatusLive     = "live"
+	ProductStatusEnded    = "ended"
+)
+
 // Order 订单模型
 type Order struct {
 	ID          uint      `gorm:"primarykey" json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
+	CreatedAt   time.Time `gorm:"index:idx_orders_user_created,priority:2;index:idx_orders_user_status_created,priority:3" json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	OrderNo     string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"order_no"`
-	UserID      string    `gorm:"type:varchar(64);not null;index" json:"user_id"`
-	ProductID   uint      `gorm:"type:int;not null;index" json:"product_id"`
+	UserID      string    `gorm:"type:varchar(64);not null;index:idx_orders_user_created,priority:1;index:idx_orders_user_status_created,priority:1" json:"user_id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
 	ProductName string    `gorm:"type:varchar(255);not null" json:"product_name"`
 	Price       float64   `gorm:"type:decimal(10,2);not null" json:"price"`
-	Status      string    `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Status      string    `gorm:"type:varchar(20);not null;default:'pending';index:idx_orders_user_status_created,priority:2" json:"status"`
 	Product     Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	// Items 组合购订单的明细，普通订单为空
+	Items []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+}
+
+// OrderItem 订单明细
+type OrderItem struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	OrderID     uint      `gorm:"not null;index" json:"order_id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
+	ProductName string    `gorm:"type:varchar(255);not null" json:"product_name"`
+	Price       float64   `gorm:"type:decimal(10,2);not null" json:"price"`
+	Quantity    int       `gorm:"type:int;not null;default:1" json:"quantity"`
 }
 
+// RuleConfig 购买资格规则配置
+type RuleConfig struct {
+	Name   string          `json:"name"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// UserProfile 用户画像，由用户系统同步，用于资格校验
+type UserProfile struct {
+	UserID       string    `gorm:"type:varchar(64);primarykey" json:"user_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Tier         string    `gorm:"type:varchar(32);not null;default:''" json:"tier"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// Reservation 预约记录
+type Reservation struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_reservation_user_product" json:"user_id"`
+	ProductID uint      `gorm:"not null;uniqueIndex:idx_reservation_user_product;index" json:"product_id"`
+}
+
+// AccessRule 黑白名单规则
+type AccessRule struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	List      string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_access_rule" json:"list"`
+	Kind      string     `gorm:"type:varchar(10);not null;uniqueIndex:idx_access_rule" json:"kind"`
+	Value     string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_access_rule" json:"value"`
+	Reason    string     `gorm:"type:varchar(255);not null;default:''" json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// 黑白名单类型常量
+const (
+	AccessListBlock = "block"
+	AccessListAllow = "allow"
+
+	AccessKindUser = "user"
+	AccessKindIP   = "ip"
+)
+
 // OrderStatus 订单状态常量
 const (
 	OrderStatusPending   = "pending"
@@ -42,3 +143,60 @@ const (
 	OrderStatusCompleted = "completed"
 )
 
+// OutboxEvent 事务发件箱事件，与业务数据在同一事务中写入，由中继投递给下游
+type OutboxEvent struct {
+	ID            uint       `gorm:"primarykey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	EventType     string     `gorm:"type:varchar(64);not null" json:"event_type"`
+	AggregateID   string     `gorm:"type:varchar(64);not null;index" json:"aggregate_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_outbox_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:varchar(512);not null;default:''" json:"last_error"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// TableName 发件箱表名
+func (OutboxEvent) TableName() string {
+	return "outbox"
+}
+
+// OutboxStatus 发件箱事件状态常量
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	// OutboxStatusDead 超过最大重试次数，需人工处理
+	OutboxStatusDead = "dead"
+)
+
+// 订单生命周期事件类型
+const (
+	EventOrderCreated       = "order.created"
+	EventOrderStatusChanged = "order.status_changed"
+)
+
+// OrderStatusChangedPayload 订单状态变更事件内容
+type OrderStatusChangedPayload struct {
+	OrderNo   string    `json:"order_no"`
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// AuditLog 管理操作审计日志，Diff 格式为 {"字段": {"before": 旧值, "after": 新值}}
+type AuditLog struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Actor      string    `gorm:"type:varchar(64);not null;index" json:"actor"`
+	Action     string    `gorm:"type:varchar(64);not null;index" json:"action"`
+	TargetType string    `gorm:"type:varchar(32);not null;default:'';index:idx_audit_target,priority:1" json:"target_type"`
+	TargetID   string    `gorm:"type:varchar(64);not null;default:'';index:idx_audit_target,priority:2" json:"target_id"`
+	Before     string    `gorm:"type:text" json:"before,omitempty"`
+	After      string    `gorm:"type:text" json:"after,omitempty"`
+	Diff       string    `gorm:"type:text" json:"diff,omitempty"`
+	IP         string    `gorm:"type:varchar(64);not null;default:''" json:"ip"`
+	Method     string    `gorm:"type:varchar(10);not null;default:''" json:"method"`
+	Path       string    `gorm:"type:varchar(255);not null;default:''" json:"path"`
+	StatusCode int       `gorm:"not null;default:0" json:"status_code"`
+}
@@ -0,0 +1,115 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/models"
+)
+
+// Publisher 事件投递接口，投递成功返回nil，失败的事件会被重试，下游需按事件ID幂等处理
+type Publisher interface {
+	Publish(ctx context.Context, event *models.OutboxEvent) error
+}
+
+// NewPublisher 根据配置创建事件投递器
+func NewPublisher(cfg *config.Config) (Publisher, error) {
+	switch cfg.Outbox.Publisher {
+	case "log":
+		return LogPublisher{}, nil
+	case "http":
+		if cfg.Outbox.HTTPURL == "" {
+			return nil, fmt.Errorf("OUTBOX_HTTP_URL is required for the http publisher")
+		}
+		return &HTTPPublisher{
+			URL:    cfg.Outbox.HTTPURL,
+			Client: &http.Client{Timeout: time.Duration(cfg.Outbox.HTTPTimeout) * time.Second},
+		}, nil
+	case "redis":
+		return &RedisStreamPublisher{Stream: cfg.Outbox.Stream}, nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q, expected log|http|redis", cfg.Outbox.Publisher)
+	}
+}
+
+// message 投递给下游的事件格式
+type message struct {
+	ID          uint            `json:"id"`
+	EventType   string          `json:"event_type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func newMessage(event *models.OutboxEvent) message {
+	return message{
+		ID:          event.ID,
+		EventType:   event.EventType,
+		AggregateID: event.AggregateID,
+		Payload:     json.RawMessage(event.Payload),
+		CreatedAt:   event.CreatedAt,
+	}
+}
+
+// LogPublisher 将事件写入日志，用于开发环境
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	log.Printf("outbox event %d %s %s: %s", event.ID, event.EventType, event.AggregateID, event.Payload)
+	return nil
+}
+
+// HTTPPublisher 将事件以JSON POST到下游地址，2xx视为投递成功
+type HTTPPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	body, err := json.Marshal(newMessage(event))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", strconv.FormatUint(uint64(event.ID), 10))
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, p.URL)
+	}
+	return nil
+}
+
+// RedisStreamPublisher 将事件追加到Redis Stream，下游以消费组读取
+type RedisStreamPublisher struct {
+	Stream string
+}
+
+func (p *RedisStreamPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	_, err := cache.XAdd(p.Stream, map[string]interface{}{
+		"id":           event.ID,
+		"event_type":   event.EventType,
+		"aggregate_id": event.AggregateID,
+		"payload":      event.Payload,
+		"created_at":   event.CreatedAt.Format(time.RFC3339Nano),
+	})
+	return err
+}
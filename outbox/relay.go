@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go-seckill/config"
+	"go-seckill/models"
+	"go-seckill/repository"
+	"go-seckill/utils"
+)
+
+// Relay 发件箱中继，轮询未投递的事件并交给Publisher，投递成功后标记为已发送
+// 事件先投递后标记，进程在两步之间退出会导致重复投递，即至少一次语义
+type Relay struct {
+	cfg       *config.Config
+	events    repository.OutboxRepository
+	publisher Publisher
+}
+
+// NewRelay 创建发件箱中继
+func NewRelay(cfg *config.Config, events repository.OutboxRepository, publisher Publisher) *Relay {
+	return &Relay{
+		cfg:       cfg,
+		events:    events,
+		publisher: publisher,
+	}
+}
+
+// Start 启动后台轮询，ctx取消时退出
+func (r *Relay) Start(ctx context.Context) {
+	interval := time.Duration(r.cfg.Outbox.PollInterval) * time.Millisecond
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := r.RunOnce(ctx); err != nil {
+					log.Printf("Outbox relay failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// RunOnce 投递一批到期事件，返回投递成功的数量
+// 多实例部署时通过分布式锁保证同一时刻只有一个中继在投递，避免同一事件被并发重复投递
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	lock := utils.NewDistributedLock(r.cfg.Outbox.LockKey, 30*time.Second)
+	locked, err := lock.Lock()
+	if err != nil || !locked {
+		return 0, err
+	}
+	defer lock.Unlock()
+	// 一批事件的投递时间可能超过锁的有效期，失去锁后停止投递，剩余事件由新的持锁实例处理
+	lost := lock.StartWatchdog(ctx)
+
+	events, err := r.events.FetchDue(time.Now(), r.cfg.Outbox.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case err := <-lost:
+			if err != nil {
+				return sent, err
+			}
+		default:
+		}
+		event := &events[i]
+		if err := r.publisher.Publish(ctx, event); err != nil {
+			r.fail(event, err)
+			continue
+		}
+		if err := r.events.MarkSent(event.ID, time.Now()); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// fail 记录投递失败，按指数退避安排下次投递，超过最大次数后标记为dead
+func (r *Relay) fail(event *models.OutboxEvent, publishErr error) {
+	event.Attempts++
+	event.LastError = truncate(publishErr.Error(), 512)
+	if event.Attempts >= r.cfg.Outbox.MaxAttempts {
+		event.Status = models.OutboxStatusDead
+		log.Printf("Outbox event %d gave up after %d attempts: %v", event.ID, event.Attempts, publishErr)
+	} else {
+		event.NextAttemptAt = time.Now().Add(r.backoff(event.Attempts))
+	}
+
+	if err := r.events.MarkFailed(event); err != nil {
+		log.Printf("Failed to record outbox event %d failure: %v", event.ID, err)
+	}
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	max := time.Duration(r.cfg.Outbox.MaxBackoff) * time.Second
+	if attempts > 30 {
+		return max
+	}
+	delay := time.Duration(1<<uint(attempts-1)) * time.Second
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,107 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// flakyPublisher 前 failures 次投递失败，之后成功
+type flakyPublisher struct {
+	failures  int
+	published []uint
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event *models.OutboxEvent) error {
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("downstream unavailable")
+	}
+	p.published = append(p.published, event.ID)
+	return nil
+}
+
+func newTestRelay(t *testing.T, publisher Publisher) (*Relay, *repository.Repositories) {
+	t.Helper()
+	cache.SetStore(cache.NewMemoryStore())
+
+	cfg := config.Load()
+	cfg.Outbox.MaxAttempts = 2
+	repos := repository.NewMemoryRepositories()
+	return NewRelay(cfg, repos.Outbox, publisher), repos
+}
+
+func TestOrderLifecycleWritesOutboxEvents(t *testing.T) {
+	_, repos := newTestRelay(t, LogPublisher{})
+
+	order := &models.Order{OrderNo: "ORD001", UserID: "u1", ProductID: 1, Status: models.OrderStatusPending}
+	if err := repos.Orders.Create(order); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := repos.Orders.UpdateStatus("ORD001", models.OrderStatusPaid); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+
+	events := repos.Outbox.(*repository.MemoryOutboxRepository).Events()
+	if len(events) != 2 || events[0].EventType != models.EventOrderCreated || events[1].EventType != models.EventOrderStatusChanged {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	for _, event := range events {
+		if event.AggregateID != "ORD001" || event.Status != models.OutboxStatusPending {
+			t.Errorf("unexpected event %+v", event)
+		}
+	}
+}
+
+func TestRelayRetriesUntilPublished(t *testing.T) {
+	publisher := &flakyPublisher{failures: 1}
+	relay, repos := newTestRelay(t, publisher)
+	repos.Orders.Create(&models.Order{OrderNo: "ORD001", UserID: "u1", ProductID: 1})
+
+	if sent, err := relay.RunOnce(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("first RunOnce = %d, %v; want 0 sent", sent, err)
+	}
+	event := repos.Outbox.(*repository.MemoryOutboxRepository).Events()[0]
+	if event.Attempts != 1 || event.Status != models.OutboxStatusPending || !event.NextAttemptAt.After(time.Now()) {
+		t.Fatalf("event after failure = %+v; want a scheduled retry", event)
+	}
+
+	// 退避期内不重复投递
+	if sent, _ := relay.RunOnce(context.Background()); sent != 0 {
+		t.Fatalf("event was retried before its backoff elapsed")
+	}
+
+	event.NextAttemptAt = time.Now()
+	repos.Outbox.MarkFailed(&event)
+	if sent, err := relay.RunOnce(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("retry RunOnce = %d, %v; want 1 sent", sent, err)
+	}
+	event = repos.Outbox.(*repository.MemoryOutboxRepository).Events()[0]
+	if event.Status != models.OutboxStatusSent || event.SentAt == nil || len(publisher.published) != 1 {
+		t.Errorf("event after retry = %+v", event)
+	}
+}
+
+func TestRelayGivesUpAfterMaxAttempts(t *testing.T) {
+	relay, repos := newTestRelay(t, &flakyPublisher{failures: 10})
+	repos.Orders.Create(&models.Order{OrderNo: "ORD001", UserID: "u1", ProductID: 1})
+	outbox := repos.Outbox.(*repository.MemoryOutboxRepository)
+
+	for i := 0; i < 2; i++ {
+		event := outbox.Events()[0]
+		event.NextAttemptAt = time.Now()
+		outbox.MarkFailed(&event)
+		relay.RunOnce(context.Background())
+	}
+
+	event := outbox.Events()[0]
+	if event.Status != models.OutboxStatusDead || event.Attempts != 2 || event.LastError == "" {
+		t.Errorf("event = %+v; want dead after 2 attempts", event)
+	}
+}
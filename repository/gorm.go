@@ -0,0 +1,564 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-seckill/models"
+	"go-seckill/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGormRepositories 创建基于GORM的数据访问层
+// reader 返回从库连接，为nil时读请求全部走主库；orderShards 为订单分片数
+func NewGormRepositories(db *gorm.DB, reader func() *gorm.DB, orderShards int) *Repositories {
+	return &Repositories{
+		Products:     &gormProductRepository{db: db, reader: reader},
+		Orders:       &gormOrderRepository{db: db, reader: reader, shards: orderShards},
+		Reservations: &gormReservationRepository{db: db},
+		Users:        &gormUserProfileRepository{db: db},
+		AccessRules:  &gormAccessRuleRepository{db: db},
+		Outbox:       &gormOutboxRepository{db: db},
+		Audit:        &gormAuditRepository{db: db},
+	}
+}
+
+// translateError 将GORM的未找到错误转换为ErrNotFound
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// readDB 根据读选项选择主库或从库
+func readDB(db *gorm.DB, reader func() *gorm.DB, opts []ReadOption) *gorm.DB {
+	o := applyReadOptions(opts)
+	if reader != nil && o.replica {
+		db = reader()
+	}
+	if o.deleted {
+		db = db.Unscoped()
+	}
+	return db
+}
+
+type gormProductRepository struct {
+	db     *gorm.DB
+	reader func() *gorm.DB
+}
+
+func (r *gormProductRepository) FindByID(id uint, opts ...ReadOption) (*models.Product, error) {
+	var product models.Product
+	if err := readDB(r.db, r.reader, opts).First(&product, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &product, nil
+}
+
+func (r *gormProductRepository) List(filter ProductFilter, opts ...ReadOption) ([]models.Product, error) {
+	db := readDB(r.db, r.reader, opts).Model(&models.Product{})
+	switch filter.Status {
+	case models.ProductStatusUpcoming:
+		db = db.Where("start_time > ?", filter.Now)
+	case models.ProductStatusLive:
+		db = db.Where("start_time <= ? AND end_time > ?", filter.Now, filter.Now)
+	case models.ProductStatusEnded:
+		db = db.Where("end_time <= ?", filter.Now)
+	}
+	if !filter.From.IsZero() {
+		db = db.Where("end_time > ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		db = db.Where("start_time < ?", filter.To)
+	}
+	if filter.Name != "" {
+		db = db.Where("name LIKE ? ESCAPE '!'", "%"+escapeLike(filter.Name)+"%")
+	}
+	if filter.AfterID != 0 {
+		db = db.Where("id > ?", filter.AfterID)
+	}
+	if filter.Limit > 0 {
+		db = db.Limit(filter.Limit)
+	}
+
+	var products []models.Product
+	if err := db.Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// escapeLike 转义LIKE模式中的通配符
+// 使用 '!' 作为转义符：MySQL 默认把反斜杠当作字符串转义，PostgreSQL 和 SQLite 则不会
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func (r *gormProductRepository) Create(product *models.Product) error {
+	return r.db.Create(product).Error
+}
+
+func (r *gormProductRepository) Update(product *models.Product, fields ...string) error {
+	if len(fields) == 0 {
+		return r.db.Save(product).Error
+	}
+	return r.db.Model(product).Select(fields).Updates(product).Error
+}
+
+func (r *gormProductRepository) Delete(id uint) error {
+	result := r.db.Delete(&models.Product{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormProductRepository) Restore(id uint) (*models.Product, error) {
+	result := r.db.Unscoped().Model(&models.Product{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	return r.FindByID(id)
+}
+
+type gormOrderRepository struct {
+	db     *gorm.DB
+	reader func() *gorm.DB
+	shards int
+}
+
+// orderTables 订单表及其明细表
+type orderTables struct {
+	orders string
+	items  string
+}
+
+// legacyOrderTables 未分片时的订单表，旧格式订单号的订单在重新分片前也保存在这里
+var legacyOrderTables = orderTables{orders: "orders", items: "order_items"}
+
+// productFilter 按商品过滤订单的条件，两个参数都是商品ID；
+// 组合购订单的product_id只记录第一个商品，其余商品需要查明细
+func (t orderTables) productFilter() string {
+	return fmt.Sprintf("%s.product_id = ? OR EXISTS (SELECT 1 FROM %s WHERE %s.order_id = %s.id AND %s.product_id = ?)",
+		t.orders, t.items, t.items, t.orders, t.items)
+}
+
+func (r *gormOrderRepository) shardTables(shard int) orderTables {
+	return orderTables{orders: r.orderTable(shard), items: r.itemTable(shard)}
+}
+
+// locateOrder 根据订单号返回可能保存该订单的表，按查找顺序排列。
+// 订单号中的分片数与当前一致时直接路由到对应分片；旧格式订单号和分片数调整前生成的订单号
+// 先查未分片的 orders 表，再依次查各分片（RebalanceOrderShards 会把订单迁移到用户所在的新分片）
+func (r *gormOrderRepository) locateOrder(orderNo string) ([]orderTables, error) {
+	if err := checkOrderNo(orderNo); err != nil {
+		return nil, err
+	}
+	if r.shards <= 1 {
+		return []orderTables{legacyOrderTables}, nil
+	}
+	if shard, shards, ok := utils.ParseOrderShard(orderNo); ok && shards == r.shards {
+		return []orderTables{r.shardTables(shard)}, nil
+	}
+
+	candidates := []orderTables{legacyOrderTables}
+	for shard := 0; shard < r.shards; shard++ {
+		candidates = append(candidates, r.shardTables(shard))
+	}
+	return candidates, nil
+}
+
+func (r *gormOrderRepository) orderTable(shard int) string {
+	return utils.OrderTable(shard, r.shards)
+}
+
+func (r *gormOrderRepository) itemTable(shard int) string {
+	return utils.OrderItemTable(shard, r.shards)
+}
+
+// Create 订单写入用户所在的分片，与订单号中记录的分片一致
+func (r *gormOrderRepository) Create(order *models.Order) error {
+	shard := utils.OrderShard(order.UserID, r.shards)
+
+	// 订单、明细和发件箱事件在同一事务中写入
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(r.orderTable(shard)).Omit(clause.Associations).Create(order).Error; err != nil {
+			return err
+		}
+		if len(order.Items) > 0 {
+			for i := range order.Items {
+				order.Items[i].OrderID = order.ID
+			}
+			if err := tx.Table(r.itemTable(shard)).Create(&order.Items).Error; err != nil {
+				return err
+			}
+		}
+
+		event, err := newOutboxEvent(models.EventOrderCreated, order.OrderNo, order)
+		if err != nil {
+			return err
+		}
+		return tx.Create(event).Error
+	})
+}
+
+func (r *gormOrderRepository) FindByOrderNo(orderNo string, opts ...ReadOption) (*models.Order, error) {
+	candidates, err := r.locateOrder(orderNo)
+	if err != nil {
+		return nil, err
+	}
+
+	db := readDB(r.db, r.reader, opts)
+	for _, tables := range candidates {
+		var order models.Order
+		err := db.Table(tables.orders).Where("order_no = ?", orderNo).First(&order).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := db.Table(tables.items).Where("order_id = ?", order.ID).Order("id").Find(&order.Items).Error; err != nil {
+			return nil, err
+		}
+		return &order, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *gormOrderRepository) UpdateStatus(orderNo, status string) error {
+	candidates, err := r.locateOrder(orderNo)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, tables := range candidates {
+			result := tx.Table(tables.orders).
+				Where("order_no = ?", orderNo).
+				Updates(map[string]interface{}{"status": status, "updated_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			event, err := newOutboxEvent(models.EventOrderStatusChanged, orderNo, models.OrderStatusChangedPayload{
+				OrderNo:   orderNo,
+				Status:    status,
+				ChangedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+			return tx.Create(event).Error
+		}
+		return nil
+	})
+}
+
+// withLegacy 分片后追加未分片的 orders 表，重新分片前旧订单仍保存在这里，与按订单号读取时的回退一致
+func (r *gormOrderRepository) withLegacy(tables ...orderTables) []orderTables {
+	if r.shards <= 1 {
+		return tables
+	}
+	return append(tables, legacyOrderTables)
+}
+
+func (r *gormOrderRepository) CountActiveByUserProduct(userID string, productID uint) (int64, error) {
+	var total int64
+	for _, tables := range r.withLegacy(r.shardTables(utils.OrderShard(userID, r.shards))) {
+		var count int64
+		err := r.db.Table(tables.orders).
+			Where("user_id = ? AND status != ?", userID, models.OrderStatusCancelled).
+			Where(tables.productFilter(), productID, productID).
+			Count(&count).Error
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func (r *gormOrderRepository) CountActiveByUser(userID string, since time.Time) (int64, error) {
+	var total int64
+	for _, tables := range r.withLegacy(r.shardTables(utils.OrderShard(userID, r.shards))) {
+		query := r.db.Table(tables.orders).
+			Where("user_id = ? AND status != ?", userID, models.OrderStatusCancelled)
+		if !since.IsZero() {
+			query = query.Where("created_at >= ?", since)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func (r *gormOrderRepository) CountActiveByProduct(productID uint) (int64, error) {
+	candidates := make([]orderTables, 0, r.shardCount()+1)
+	for shard := 0; shard < r.shardCount(); shard++ {
+		candidates = append(candidates, r.shardTables(shard))
+	}
+
+	var total int64
+	for _, tables := range r.withLegacy(candidates...) {
+		var count int64
+		err := r.db.Table(tables.orders).
+			Where("status != ?", models.OrderStatusCancelled).
+			Where(tables.productFilter(), productID, productID).
+			Count(&count).Error
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+func (r *gormOrderRepository) shardCount() int {
+	if r.shards <= 1 {
+		return 1
+	}
+	return r.shards
+}
+
+// Query 并发查询各分片后按创建时间倒序合并，指定用户时只查询该用户所在分片
+func (r *gormOrderRepository) Query(query OrderQuery) ([]models.Order, error) {
+	shards := make([]int, 0, r.shardCount())
+	if query.UserID != "" {
+		shards = append(shards, utils.OrderShard(query.UserID, r.shards))
+	} else {
+		for shard := 0; shard < r.shardCount(); shard++ {
+			shards = append(shards, shard)
+		}
+	}
+
+	results := make([][]models.Order, len(shards))
+	errs := make([]error, len(shards))
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i, shard int) {
+			defer wg.Done()
+			results[i], errs[i] = r.queryShard(shard, query)
+		}(i, shard)
+	}
+	wg.Wait()
+
+	var orders []models.Order
+	for i := range shards {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		orders = append(orders, results[i]...)
+	}
+	sortOrders(orders, r.shards)
+	if query.Limit > 0 && len(orders) > query.Limit {
+		orders = orders[:query.Limit]
+	}
+	return orders, nil
+}
+
+func (r *gormOrderRepository) queryShard(shard int, query OrderQuery) ([]models.Order, error) {
+	tables := r.shardTables(shard)
+	db := readDB(r.db, r.reader, []ReadOption{FromReplica()}).Table(tables.orders)
+	if query.UserID != "" {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.ProductID != 0 {
+		db = db.Where(tables.productFilter(), query.ProductID, query.ProductID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until)
+	}
+	if before := query.Before; before != nil {
+		// 创建时间相同时，分片号小于游标的分片整体排在后面，大于游标的分片整体排在前面
+		switch {
+		case shard < before.Shard:
+			db = db.Where("created_at <= ?", before.CreatedAt)
+		case shard == before.Shard:
+			db = db.Where("created_at < ? OR (created_at = ? AND id < ?)",
+				before.CreatedAt, before.CreatedAt, before.ID)
+		default:
+			db = db.Where("created_at < ?", before.CreatedAt)
+		}
+	}
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	var orders []models.Order
+	err := db.Order("created_at DESC, id DESC").Find(&orders).Error
+	return orders, err
+}
+
+type gormReservationRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReservationRepository) Create(reservation *models.Reservation) error {
+	return r.db.Create(reservation).Error
+}
+
+func (r *gormReservationRepository) Exists(userID string, productID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.Reservation{}).
+		Where("user_id = ? AND product_id = ?", userID, productID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormReservationRepository) CountByProduct(productID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Reservation{}).
+		Where("product_id = ?", productID).
+		Count(&count).Error
+	return count, err
+}
+
+type gormUserProfileRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserProfileRepository) FindByUserID(userID string) (*models.UserProfile, error) {
+	var profile models.UserProfile
+	if err := r.db.Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &profile, nil
+}
+
+type gormAccessRuleRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAccessRuleRepository) List() ([]models.AccessRule, error) {
+	var rules []models.AccessRule
+	if err := r.db.Order("id").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *gormAccessRuleRepository) FindByID(id uint) (*models.AccessRule, error) {
+	var rule models.AccessRule
+	if err := r.db.First(&rule, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &rule, nil
+}
+
+func (r *gormAccessRuleRepository) FindByValue(list, kind, value string) (*models.AccessRule, error) {
+	var rule models.AccessRule
+	if err := r.db.Where("list = ? AND kind = ? AND value = ?", list, kind, value).First(&rule).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &rule, nil
+}
+
+func (r *gormAccessRuleRepository) Upsert(rule *models.AccessRule) error {
+	if err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "list"}, {Name: "kind"}, {Name: "value"}},
+		DoUpdates: clause.AssignmentColumns([]string{"reason", "expires_at", "updated_at"}),
+	}).Create(rule).Error; err != nil {
+		return err
+	}
+	return r.db.
+		Where("list = ? AND kind = ? AND value = ?", rule.List, rule.Kind, rule.Value).
+		First(rule).Error
+}
+
+func (r *gormAccessRuleRepository) Delete(id uint) error {
+	return r.db.Delete(&models.AccessRule{}, id).Error
+}
+
+type gormOutboxRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormOutboxRepository) FetchDue(now time.Time, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.
+		Where("status = ? AND next_attempt_at <= ?", models.OutboxStatusPending, now).
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (r *gormOutboxRepository) MarkSent(id uint, sentAt time.Time) error {
+	return r.db.Model(&models.OutboxEvent{ID: id}).Updates(map[string]interface{}{
+		"status":  models.OutboxStatusSent,
+		"sent_at": sentAt,
+	}).Error
+}
+
+func (r *gormOutboxRepository) MarkFailed(event *models.OutboxEvent) error {
+	return r.db.Model(event).Select("status", "attempts", "next_attempt_at", "last_error").Updates(event).Error
+}
+
+type gormAuditRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAuditRepository) Create(entry *models.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *gormAuditRepository) List(filter AuditFilter) ([]models.AuditLog, error) {
+	db := r.db.Model(&models.AuditLog{})
+	if filter.Actor != "" {
+		db = db.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		db = db.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		db = db.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		db = db.Where("target_id = ?", filter.TargetID)
+	}
+	if !filter.Since.IsZero() {
+		db = db.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		db = db.Where("created_at < ?", filter.Until)
+	}
+	if filter.BeforeID != 0 {
+		db = db.Where("id < ?", filter.BeforeID)
+	}
+	if filter.Limit > 0 {
+		db = db.Limit(filter.Limit)
+	}
+
+	var entries []models.AuditLog
+	err := db.Order("id DESC").Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,516 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"go-seckill/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrDuplicate 内存实现中违反唯一约束
+var ErrDuplicate = errors.New("duplicate record")
+
+// NewMemoryRepositories 创建基于内存的数据访问层，用于测试
+func NewMemoryRepositories() *Repositories {
+	outbox := &MemoryOutboxRepository{}
+	return &Repositories{
+		Products:     &memoryProductRepository{products: make(map[uint]models.Product)},
+		Orders:       &memoryOrderRepository{orders: make(map[string]models.Order), outbox: outbox},
+		Reservations: &memoryReservationRepository{},
+		Users:        &MemoryUserProfileRepository{profiles: make(map[string]models.UserProfile)},
+		AccessRules:  &memoryAccessRuleRepository{rules: make(map[uint]models.AccessRule)},
+		Outbox:       outbox,
+		Audit:        &memoryAuditRepository{},
+	}
+}
+
+type memoryProductRepository struct {
+	mu       sync.RWMutex
+	nextID   uint
+	products map[uint]models.Product
+}
+
+func (r *memoryProductRepository) FindByID(id uint, opts ...ReadOption) (*models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt.Valid && !applyReadOptions(opts).deleted {
+		return nil, ErrNotFound
+	}
+	return &product, nil
+}
+
+func (r *memoryProductRepository) List(filter ProductFilter, opts ...ReadOption) ([]models.Product, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	deleted := applyReadOptions(opts).deleted
+	products := make([]models.Product, 0, len(r.products))
+	for _, product := range r.products {
+		if (deleted || !product.DeletedAt.Valid) && matchProduct(&product, filter) {
+			products = append(products, product)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].ID < products[j].ID })
+	if filter.Limit > 0 && len(products) > filter.Limit {
+		products = products[:filter.Limit]
+	}
+	return products, nil
+}
+
+func (r *memoryProductRepository) Create(product *models.Product) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	product.ID = r.nextID
+	product.CreatedAt = now
+	product.UpdatedAt = now
+	r.products[product.ID] = *product
+	return nil
+}
+
+func (r *memoryProductRepository) Update(product *models.Product, fields ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.products[product.ID]; !ok || existing.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	product.UpdatedAt = time.Now()
+	r.products[product.ID] = *product
+	return nil
+}
+
+func (r *memoryProductRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.products[id]
+	if !ok || product.DeletedAt.Valid {
+		return ErrNotFound
+	}
+	product.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.products[id] = product
+	return nil
+}
+
+func (r *memoryProductRepository) Restore(id uint) (*models.Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, ok := r.products[id]
+	if !ok || !product.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	product.DeletedAt = gorm.DeletedAt{}
+	product.UpdatedAt = time.Now()
+	r.products[id] = product
+	return &product, nil
+}
+
+type memoryOrderRepository struct {
+	mu         sync.RWMutex
+	nextID     uint
+	nextItemID uint
+	orders     map[string]models.Order
+	outbox     *MemoryOutboxRepository
+}
+
+func (r *memoryOrderRepository) Create(order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.orders[order.OrderNo]; ok {
+		return ErrDuplicate
+	}
+
+	r.nextID++
+	now := time.Now()
+	order.ID = r.nextID
+	order.CreatedAt = now
+	order.UpdatedAt = now
+	for i := range order.Items {
+		r.nextItemID++
+		order.Items[i].ID = r.nextItemID
+		order.Items[i].OrderID = order.ID
+		order.Items[i].CreatedAt = now
+	}
+
+	stored := *order
+	stored.Items = append([]models.OrderItem(nil), order.Items...)
+	r.orders[order.OrderNo] = stored
+	return r.outbox.add(models.EventOrderCreated, order.OrderNo, order)
+}
+
+func (r *memoryOrderRepository) FindByOrderNo(orderNo string, opts ...ReadOption) (*models.Order, error) {
+	if err := checkOrderNo(orderNo); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	order, ok := r.orders[orderNo]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	order.Items = append([]models.OrderItem(nil), order.Items...)
+	return &order, nil
+}
+
+func (r *memoryOrderRepository) UpdateStatus(orderNo, status string) error {
+	if err := checkOrderNo(orderNo); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[orderNo]
+	if !ok {
+		return nil
+	}
+	order.Status = status
+	order.UpdatedAt = time.Now()
+	r.orders[orderNo] = order
+	return r.outbox.add(models.EventOrderStatusChanged, orderNo, models.OrderStatusChangedPayload{
+		OrderNo:   orderNo,
+		Status:    status,
+		ChangedAt: order.UpdatedAt,
+	})
+}
+
+func (r *memoryOrderRepository) count(match func(order *models.Order) bool) int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, order := range r.orders {
+		if order.Status != models.OrderStatusCancelled && match(&order) {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *memoryOrderRepository) CountActiveByUserProduct(userID string, productID uint) (int64, error) {
+	return r.count(func(order *models.Order) bool {
+		return order.UserID == userID && orderHasProduct(order, productID)
+	}), nil
+}
+
+// orderHasProduct 订单的product_id或明细中含有该商品
+func orderHasProduct(order *models.Order, productID uint) bool {
+	if order.ProductID == productID {
+		return true
+	}
+	for _, item := range order.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryOrderRepository) CountActiveByUser(userID string, since time.Time) (int64, error) {
+	return r.count(func(order *models.Order) bool {
+		return order.UserID == userID && !order.CreatedAt.Before(since)
+	}), nil
+}
+
+func (r *memoryOrderRepository) CountActiveByProduct(productID uint) (int64, error) {
+	return r.count(func(order *models.Order) bool {
+		return orderHasProduct(order, productID)
+	}), nil
+}
+
+func (r *memoryOrderRepository) Query(query OrderQuery) ([]models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var orders []models.Order
+	for _, order := range r.orders {
+		if query.UserID != "" && order.UserID != query.UserID ||
+			query.ProductID != 0 && !orderHasProduct(&order, query.ProductID) ||
+			query.Status != "" && order.Status != query.Status ||
+			!query.Since.IsZero() && order.CreatedAt.Before(query.Since) ||
+			!query.Until.IsZero() && !order.CreatedAt.Before(query.Until) ||
+			!beforeCursor(&order, query.Before) {
+			continue
+		}
+		order.Items = append([]models.OrderItem(nil), order.Items...)
+		orders = append(orders, order)
+	}
+	sortOrders(orders, 1)
+	if query.Limit > 0 && len(orders) > query.Limit {
+		orders = orders[:query.Limit]
+	}
+	return orders, nil
+}
+
+type memoryReservationRepository struct {
+	mu           sync.RWMutex
+	nextID       uint
+	reservations []models.Reservation
+}
+
+func (r *memoryReservationRepository) Create(reservation *models.Reservation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.reservations {
+		if existing.UserID == reservation.UserID && existing.ProductID == reservation.ProductID {
+			return ErrDuplicate
+		}
+	}
+	r.nextID++
+	reservation.ID = r.nextID
+	reservation.CreatedAt = time.Now()
+	r.reservations = append(r.reservations, *reservation)
+	return nil
+}
+
+func (r *memoryReservationRepository) Exists(userID string, productID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, existing := range r.reservations {
+		if existing.UserID == userID && existing.ProductID == productID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryReservationRepository) CountByProduct(productID uint) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, existing := range r.reservations {
+		if existing.ProductID == productID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// MemoryUserProfileRepository 内存用户画像，测试中通过Put写入数据
+type MemoryUserProfileRepository struct {
+	mu       sync.RWMutex
+	profiles map[string]models.UserProfile
+}
+
+// Put 写入用户画像
+func (r *MemoryUserProfileRepository) Put(profile models.UserProfile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.profiles[profile.UserID] = profile
+}
+
+func (r *MemoryUserProfileRepository) FindByUserID(userID string) (*models.UserProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profile, ok := r.profiles[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &profile, nil
+}
+
+type memoryAccessRuleRepository struct {
+	mu     sync.RWMutex
+	nextID uint
+	rules  map[uint]models.AccessRule
+}
+
+func (r *memoryAccessRuleRepository) List() ([]models.AccessRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]models.AccessRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules, nil
+}
+
+func (r *memoryAccessRuleRepository) FindByID(id uint) (*models.AccessRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rules[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &rule, nil
+}
+
+func (r *memoryAccessRuleRepository) FindByValue(list, kind, value string) (*models.AccessRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rule := range r.rules {
+		if rule.List == list && rule.Kind == kind && rule.Value == value {
+			return &rule, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryAccessRuleRepository) Upsert(rule *models.AccessRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range r.rules {
+		if existing.List == rule.List && existing.Kind == rule.Kind && existing.Value == rule.Value {
+			existing.Reason = rule.Reason
+			existing.ExpiresAt = rule.ExpiresAt
+			existing.UpdatedAt = now
+			r.rules[id] = existing
+			*rule = existing
+			return nil
+		}
+	}
+
+	r.nextID++
+	rule.ID = r.nextID
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+	r.rules[rule.ID] = *rule
+	return nil
+}
+
+func (r *memoryAccessRuleRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.rules, id)
+	return nil
+}
+
+// MemoryOutboxRepository 内存发件箱，测试中通过Events查看已写入的事件
+type MemoryOutboxRepository struct {
+	mu     sync.Mutex
+	nextID uint
+	events []models.OutboxEvent
+}
+
+func (r *MemoryOutboxRepository) add(eventType, aggregateID string, payload interface{}) error {
+	event, err := newOutboxEvent(eventType, aggregateID, payload)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	event.ID = r.nextID
+	event.CreatedAt = event.NextAttemptAt
+	event.UpdatedAt = event.NextAttemptAt
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// Events 返回全部事件的副本
+func (r *MemoryOutboxRepository) Events() []models.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]models.OutboxEvent(nil), r.events...)
+}
+
+func (r *MemoryOutboxRepository) FetchDue(now time.Time, limit int) ([]models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []models.OutboxEvent
+	for _, event := range r.events {
+		if len(due) == limit {
+			break
+		}
+		if event.Status == models.OutboxStatusPending && !event.NextAttemptAt.After(now) {
+			due = append(due, event)
+		}
+	}
+	return due, nil
+}
+
+func (r *MemoryOutboxRepository) MarkSent(id uint, sentAt time.Time) error {
+	return r.update(id, func(event *models.OutboxEvent) {
+		event.Status = models.OutboxStatusSent
+		event.SentAt = &sentAt
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(failed *models.OutboxEvent) error {
+	return r.update(failed.ID, func(event *models.OutboxEvent) {
+		event.Status = failed.Status
+		event.Attempts = failed.Attempts
+		event.NextAttemptAt = failed.NextAttemptAt
+		event.LastError = failed.LastError
+	})
+}
+
+func (r *MemoryOutboxRepository) update(id uint, apply func(event *models.OutboxEvent)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.events {
+		if r.events[i].ID == id {
+			apply(&r.events[i])
+			r.events[i].UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+type memoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []models.AuditLog
+}
+
+func (r *memoryAuditRepository) Create(entry *models.AuditLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = uint(len(r.entries) + 1)
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	r.entries = append(r.entries, *entry)
+	return nil
+}
+
+func (r *memoryAuditRepository) List(filter AuditFilter) ([]models.AuditLog, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []models.AuditLog
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		entry := r.entries[i]
+		if filter.Actor != "" && entry.Actor != filter.Actor ||
+			filter.Action != "" && entry.Action != filter.Action ||
+			filter.TargetType != "" && entry.TargetType != filter.TargetType ||
+			filter.TargetID != "" && entry.TargetID != filter.TargetID ||
+			!filter.Since.IsZero() && entry.CreatedAt.Before(filter.Since) ||
+			!filter.Until.IsZero() && !entry.CreatedAt.Before(filter.Until) ||
+			filter.BeforeID != 0 && entry.ID >= filter.BeforeID {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
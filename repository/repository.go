@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go-seckill/models"
+	"go-seckill/utils"
+)
+
+// ErrNotFound 记录不存在
+var ErrNotFound = errors.New("record not found")
+
+// ErrUnroutableOrder 订单号格式无法识别，不能定位到订单表
+var ErrUnroutableOrder = errors.New("order number cannot be routed")
+
+// ReadOption 读操作选项
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	replica bool
+	deleted bool
+}
+
+// FromReplica 读请求路由到从库，可接受复制延迟的查询使用
+func FromReplica() ReadOption {
+	return func(o *readOptions) { o.replica = true }
+}
+
+// WithDeleted 商品查询包含已软删除的商品
+func WithDeleted() ReadOption {
+	return func(o *readOptions) { o.deleted = true }
+}
+
+// ReadsFromReplica 判断读选项是否路由到从库，供包装仓储的实现使用
+func ReadsFromReplica(opts ...ReadOption) bool {
+	return applyReadOptions(opts).replica
+}
+
+func applyReadOptions(opts []ReadOption) readOptions {
+	var o readOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ProductRepository 商品数据访问，读操作默认走主库
+type ProductRepository interface {
+	FindByID(id uint, opts ...ReadOption) (*models.Product, error)
+	// List 按ID升序返回符合条件的商品
+	List(filter ProductFilter, opts ...ReadOption) ([]models.Product, error)
+	Create(product *models.Product) error
+	// Update 更新指定字段，fields为空时更新全部字段
+	Update(product *models.Product, fields ...string) error
+	// Delete 软删除商品，删除后的商品不会被查询到
+	Delete(id uint) error
+	// Restore 恢复软删除的商品，商品不存在或未被删除时返回ErrNotFound
+	Restore(id uint) (*models.Product, error)
+}
+
+// ProductFilter 商品列表查询条件，零值字段不参与过滤
+type ProductFilter struct {
+	// Status 销售状态 upcoming|live|ended，以Now为当前时间判断
+	Status string
+	Now    time.Time
+	// From、To 秒杀时间段与 [From, To) 有交集
+	From time.Time
+	To   time.Time
+	// Name 商品名称包含的关键字
+	Name string
+	// AfterID 分页游标，只返回ID大于该值的商品
+	AfterID uint
+	Limit   int
+}
+
+// OrderRepository 订单数据访问
+type OrderRepository interface {
+	// Create 创建订单及其明细，并在同一事务中写入 order.created 发件箱事件
+	Create(order *models.Order) error
+	FindByOrderNo(orderNo string, opts ...ReadOption) (*models.Order, error)
+	// UpdateStatus 更新订单状态，并在同一事务中写入 order.status_changed 发件箱事件
+	UpdateStatus(orderNo, status string) error
+	// CountActiveByUserProduct 统计用户在某商品上未取消的订单数，包括明细中含有该商品的组合购订单
+	CountActiveByUserProduct(userID string, productID uint) (int64, error)
+	// CountActiveByUser 统计用户自since以来未取消的订单数，since为零值时统计全部
+	CountActiveByUser(userID string, since time.Time) (int64, error)
+	// CountActiveByProduct 统计商品未取消的订单数，包括明细中含有该商品的组合购订单
+	CountActiveByProduct(productID uint) (int64, error)
+	// Query 按条件查询订单，按创建时间倒序返回，分片时会查询所有分片后合并
+	Query(query OrderQuery) ([]models.Order, error)
+}
+
+// OrderQuery 订单查询条件，零值字段不参与过滤
+type OrderQuery struct {
+	UserID string
+	// ProductID 商品ID，明细中含有该商品的组合购订单也会返回
+	ProductID uint
+	Status    string
+	Since     time.Time
+	Until     time.Time
+	// Before 分页游标，只返回排在该位置之后（更早）的订单
+	Before *OrderCursor
+	Limit  int
+}
+
+// OrderCursor 订单分页位置，订单按 (created_at, 分片号, id) 倒序排列。
+// 各分片表的自增ID相互独立，不同分片创建时间相同的订单只靠ID无法区分先后
+type OrderCursor struct {
+	CreatedAt time.Time
+	Shard     int
+	ID        uint
+}
+
+// NewOrderCursor 返回排在该订单之后的分页位置，分片号按用户ID计算，与订单所在的分片表一致
+func NewOrderCursor(order *models.Order, shards int) *OrderCursor {
+	return &OrderCursor{CreatedAt: order.CreatedAt, Shard: utils.OrderShard(order.UserID, shards), ID: order.ID}
+}
+
+// matchProduct 判断商品是否符合过滤条件，供内存实现使用
+func matchProduct(product *models.Product, filter ProductFilter) bool {
+	switch filter.Status {
+	case models.ProductStatusUpcoming:
+		if !product.StartTime.After(filter.Now) {
+			return false
+		}
+	case models.ProductStatusLive:
+		if product.StartTime.After(filter.Now) || !product.EndTime.After(filter.Now) {
+			return false
+		}
+	case models.ProductStatusEnded:
+		if product.EndTime.After(filter.Now) {
+			return false
+		}
+	}
+	if !filter.From.IsZero() && !product.EndTime.After(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !product.StartTime.Before(filter.To) {
+		return false
+	}
+	if filter.Name != "" && !strings.Contains(product.Name, filter.Name) {
+		return false
+	}
+	return product.ID > filter.AfterID
+}
+
+// beforeCursor 判断订单是否排在游标之后，供内存实现使用。内存实现的订单ID全局唯一，不比较分片号
+func beforeCursor(order *models.Order, cursor *OrderCursor) bool {
+	if cursor == nil {
+		return true
+	}
+	return orderAfter(&OrderCursor{CreatedAt: order.CreatedAt, Shard: cursor.Shard, ID: order.ID}, cursor)
+}
+
+// orderAfter 判断分页位置a是否排在b之后
+func orderAfter(a, b *OrderCursor) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	if a.Shard != b.Shard {
+		return a.Shard < b.Shard
+	}
+	return a.ID < b.ID
+}
+
+// ReservationRepository 预约数据访问
+type ReservationRepository interface {
+	Create(reservation *models.Reservation) error
+	Exists(userID string, productID uint) (bool, error)
+	CountByProduct(productID uint) (int64, error)
+}
+
+// UserProfileRepository 用户画像数据访问
+type UserProfileRepository interface {
+	FindByUserID(userID string) (*models.UserProfile, error)
+}
+
+// AccessRuleRepository 黑白名单规则数据访问
+type AccessRuleRepository interface {
+	List() ([]models.AccessRule, error)
+	FindByID(id uint) (*models.AccessRule, error)
+	// FindByValue 按(list, kind, value)查找规则，不存在时返回ErrNotFound
+	FindByValue(list, kind, value string) (*models.AccessRule, error)
+	// Upsert 按(list, kind, value)新增或更新规则，并回填规则ID
+	Upsert(rule *models.AccessRule) error
+	Delete(id uint) error
+}
+
+// OutboxRepository 事务发件箱数据访问，事件由订单仓储在业务事务中写入
+type OutboxRepository interface {
+	// FetchDue 按ID顺序获取到期待投递的事件
+	FetchDue(now time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkSent(id uint, sentAt time.Time) error
+	// MarkFailed 保存投递失败后的重试次数、下次投递时间、错误信息和状态
+	MarkFailed(event *models.OutboxEvent) error
+}
+
+// AuditRepository 审计日志数据访问
+type AuditRepository interface {
+	Create(entry *models.AuditLog) error
+	// List 按ID倒序返回符合条件的审计日志
+	List(filter AuditFilter) ([]models.AuditLog, error)
+}
+
+// AuditFilter 审计日志查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time
+	Until      time.Time
+	// BeforeID 分页游标，只返回ID小于该值的日志
+	BeforeID uint
+	Limit    int
+}
+
+// newOutboxEvent 构造待投递的发件箱事件
+func newOutboxEvent(eventType, aggregateID string, payload interface{}) (*models.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OutboxEvent{
+		EventType:     eventType,
+		AggregateID:   aggregateID,
+		Payload:       string(data),
+		Status:        models.OutboxStatusPending,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
+
+// checkOrderNo 只有带分片信息的订单号和旧格式订单号能定位到订单表
+func checkOrderNo(orderNo string) error {
+	if _, _, ok := utils.ParseOrderShard(orderNo); !ok && !utils.IsLegacyOrderNo(orderNo) {
+		return fmt.Errorf("%w: %s", ErrUnroutableOrder, orderNo)
+	}
+	return nil
+}
+
+// sortOrders 按创建时间倒序排列订单，时间相同时按分片号、ID倒序
+func sortOrders(orders []models.Order, shards int) {
+	sort.Slice(orders, func(i, j int) bool {
+		return orderAfter(NewOrderCursor(&orders[j], shards), NewOrderCursor(&orders[i], shards))
+	})
+}
+
+// Repositories 数据访问层集合
+type Repositories struct {
+	Products     ProductRepository
+	Orders       OrderRepository
+	Reservations ReservationRepository
+	Users        UserProfileRepository
+	AccessRules  AccessRuleRepository
+	Outbox       OutboxRepository
+	Audit        AuditRepository
+}
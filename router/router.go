@@ -1,13 +1,16 @@
 package router
 
 import (
+	"go-seckill/config"
 	"go-seckill/controller"
+	"go-seckill/database"
 	"go-seckill/middleware"
+	"go-seckill/service"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(seckillController *controller.SeckillController) *gin.Engine {
+func SetupRouter(cfg *config.Config, seckillController *controller.SeckillController, accessController *controller.AccessController, auditController *controller.AuditController, accessList *service.AccessListService, auditService *service.AuditService) *gin.Engine {
 	r := gin.Default()
 
 	// 全局中间件
@@ -15,34 +18,64 @@ func SetupRouter(seckillController *controller.SeckillController) *gin.Engine {
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		replicas := database.ReplicaStatus()
+		status := "ok"
+		for _, replica := range replicas {
+			if !replica.Healthy {
+				status = "degraded"
+			}
+		}
+		c.JSON(200, gin.H{"status": status, "replicas": replicas})
 	})
 
 	api := r.Group("/api/v1")
 	{
 		// 商品相关
 		api.GET("/products", seckillController.GetProducts)
+		api.GET("/products/stock", seckillController.GetLiveStocks)
 		api.GET("/products/:id", seckillController.GetProduct)
 
-		// 秒杀相关（需要用户级限流）
+		// 秒杀相关（需要身份认证、黑白名单检查和用户级限流）
 		seckill := api.Group("/seckill")
-		seckill.Use(middleware.UserRateLimitMiddleware())
+		seckill.Use(middleware.AuthMiddleware(cfg.Auth.UserSecret), middleware.AccessListMiddleware(accessList), middleware.UserRateLimitMiddleware())
 		{
+			seckill.POST("/reserve", seckillController.Reserve)
 			seckill.POST("/token", seckillController.GenerateToken)
+			seckill.POST("/token/bundle", seckillController.GenerateBundleToken)
 			seckill.POST("/buy", seckillController.Seckill)
+			seckill.POST("/buy/bundle", seckillController.SeckillBundle)
 		}
 
 		// 订单相关
 		api.GET("/orders/:orderNo", seckillController.GetOrder)
+		// 用户只能查询自己的订单（需要身份认证）
+		api.GET("/users/:id/orders", middleware.AuthMiddleware(cfg.Auth.UserSecret), seckillController.GetUserOrders)
 
-		// 管理接口
+		// 管理接口（需要管理员身份认证，所有请求记录审计日志，认证失败的请求同样记录）
 		admin := api.Group("/admin")
+		admin.Use(middleware.AuditMiddleware(auditService), middleware.AuthMiddleware(cfg.Auth.AdminSecret))
 		{
 			admin.POST("/products", seckillController.CreateProduct)
+			admin.PUT("/products/:id", seckillController.UpdateProduct)
+			admin.DELETE("/products/:id", seckillController.DeleteProduct)
+			admin.POST("/products/:id/restore", seckillController.RestoreProduct)
+			admin.PUT("/products/:id/rules", seckillController.UpdateProductRules)
+			admin.GET("/products/:id/stats", seckillController.GetProductStats)
+			admin.POST("/products/:id/stock/adjust", seckillController.AdjustStock)
+			admin.GET("/products/:id/ledger", seckillController.GetStockLedger)
+			admin.GET("/cache/stats", seckillController.GetCacheStats)
+			admin.GET("/orders", seckillController.QueryOrders)
 			admin.PUT("/orders/status", seckillController.UpdateOrderStatus)
+
+			// 黑白名单
+			admin.GET("/acl", accessController.ListRules)
+			admin.POST("/acl", accessController.AddRule)
+			admin.DELETE("/acl/:id", accessController.RemoveRule)
+
+			// 审计日志
+			admin.GET("/audit", auditController.ListAuditLogs)
 		}
 	}
 
 	return r
 }
-
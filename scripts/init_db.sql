@@ -1,41 +1,5 @@
 -- 初始化数据库脚本
 CREATE DATABASE IF NOT EXISTS seckill CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-USE seckill;
-
--- 注意：表结构会由GORM自动创建
--- 这里只是为了参考
-
--- 商品表
-CREATE TABLE IF NOT EXISTS products (
-    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    deleted_at TIMESTAMP NULL,
-    name VARCHAR(255) NOT NULL,
-    price DECIMAL(10,2) NOT NULL,
-    stock INT NOT NULL DEFAULT 0,
-    seckill_stock INT NOT NULL DEFAULT 0,
-    start_time DATETIME NOT NULL,
-    end_time DATETIME NOT NULL,
-    INDEX idx_start_time (start_time),
-    INDEX idx_end_time (end_time)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-
--- 订单表
-CREATE TABLE IF NOT EXISTS orders (
-    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
-    order_no VARCHAR(64) NOT NULL UNIQUE,
-    user_id VARCHAR(64) NOT NULL,
-    product_id BIGINT UNSIGNED NOT NULL,
-    product_name VARCHAR(255) NOT NULL,
-    price DECIMAL(10,2) NOT NULL,
-    status VARCHAR(20) NOT NULL DEFAULT 'pending',
-    INDEX idx_user_id (user_id),
-    INDEX idx_product_id (product_id),
-    INDEX idx_order_no (order_no),
-    INDEX idx_status (status)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
-
+-- 表结构由版本化迁移管理，见 database/migrations/，执行：
+--   go run main.go migrate up
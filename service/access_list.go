@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go-seckill/audit"
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// ErrInvalidAccessRule 黑白名单规则参数校验失败
+var ErrInvalidAccessRule = errors.New("invalid access rule")
+
+// AccessListService 黑白名单服务
+// 规则以MySQL为准，Redis哈希保存全量副本，每个实例在本地维护一份快照，
+// 通过Redis发布订阅感知变更，检查时无需访问Redis。
+type AccessListService struct {
+	cfg   *config.Config
+	rules repository.AccessRuleRepository
+
+	mu       sync.RWMutex
+	snapshot *aclSnapshot
+}
+
+// aclEntry 本地快照中的单条规则
+type aclEntry struct {
+	reason    string
+	expiresAt *time.Time
+	network   *net.IPNet
+}
+
+func (e *aclEntry) active(now time.Time) bool {
+	return e.expiresAt == nil || now.Before(*e.expiresAt)
+}
+
+// aclSnapshot 黑白名单本地快照
+type aclSnapshot struct {
+	blockedUsers map[string]*aclEntry
+	allowedUsers map[string]*aclEntry
+	blockedNets  []*aclEntry
+	allowedNets  []*aclEntry
+}
+
+func NewAccessListService(cfg *config.Config, rules repository.AccessRuleRepository) *AccessListService {
+	return &AccessListService{
+		cfg:      cfg,
+		rules:    rules,
+		snapshot: buildACLSnapshot(nil),
+	}
+}
+
+// Start 加载规则并订阅变更通知，ctx结束后停止
+func (s *AccessListService) Start(ctx context.Context) error {
+	if err := s.Reload(); err != nil {
+		return err
+	}
+
+	pubsub := cache.Subscribe(s.cfg.Seckill.ACLChannel)
+	go func() {
+		defer pubsub.Close()
+
+		// 兜底刷新间隔不大于0时只依赖变更通知
+		var refresh <-chan time.Time
+		if interval := s.cfg.Seckill.ACLRefreshInterval; interval > 0 {
+			ticker := time.NewTicker(time.Duration(interval) * time.Second)
+			defer ticker.Stop()
+			refresh = ticker.C
+		}
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ch:
+			case <-refresh:
+			}
+			if err := s.Reload(); err != nil {
+				log.Printf("Failed to reload access list: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Reload 从Redis重新加载规则，Redis为空时从数据库恢复
+func (s *AccessListService) Reload() error {
+	fields, err := cache.HGetAll(s.cfg.Seckill.ACLKey)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var rules []models.AccessRule
+	if len(fields) == 0 {
+		stored, err := s.rules.List()
+		if err != nil {
+			return err
+		}
+		// 数据库保留过期规则的记录，只把未过期的规则写回Redis
+		for i := range stored {
+			if !ruleActive(&stored[i], now) {
+				continue
+			}
+			if err := s.cacheRule(&stored[i]); err != nil {
+				return err
+			}
+			rules = append(rules, stored[i])
+		}
+	} else {
+		for field, value := range fields {
+			var rule models.AccessRule
+			if err := json.Unmarshal([]byte(value), &rule); err != nil {
+				log.Printf("Skipping malformed access rule %s: %v", field, err)
+				continue
+			}
+			// 过期规则从Redis副本中清理，数据库保留记录
+			if !ruleActive(&rule, now) {
+				cache.HDel(s.cfg.Seckill.ACLKey, field)
+				continue
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	snapshot := buildACLSnapshot(rules)
+	s.mu.Lock()
+	s.snapshot = snapshot
+	s.mu.Unlock()
+	return nil
+}
+
+// Check 检查用户和IP是否允许访问，按以下顺序匹配，先命中的规则生效：
+//  1. 用户黑名单：被封禁的用户不能通过IP白名单绕过
+//  2. 用户白名单：白名单用户不受IP黑名单限制（如共用出口IP的企业用户）
+//  3. IP规则：取包含该IP的最小网段，同一网段同时在黑白名单时黑名单优先
+//
+// 都未命中时允许访问
+func (s *AccessListService) Check(userID, ip string) (bool, string) {
+	s.mu.RLock()
+	snapshot := s.snapshot
+	s.mu.RUnlock()
+
+	now := time.Now()
+	if userID != "" {
+		if e, ok := snapshot.blockedUsers[userID]; ok && e.active(now) {
+			return false, blockReason("user blocked", e.reason)
+		}
+		if e, ok := snapshot.allowedUsers[userID]; ok && e.active(now) {
+			return true, ""
+		}
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return true, ""
+	}
+	blocked := longestMatch(snapshot.blockedNets, addr, now)
+	allowed := longestMatch(snapshot.allowedNets, addr, now)
+	if blocked != nil && (allowed == nil || prefixLen(blocked) >= prefixLen(allowed)) {
+		return false, blockReason("ip blocked", blocked.reason)
+	}
+	return true, ""
+}
+
+// longestMatch 返回包含addr的最小网段的有效规则
+func longestMatch(entries []*aclEntry, addr net.IP, now time.Time) *aclEntry {
+	var best *aclEntry
+	for _, e := range entries {
+		if e.active(now) && e.network.Contains(addr) && (best == nil || prefixLen(e) > prefixLen(best)) {
+			best = e
+		}
+	}
+	return best
+}
+
+func prefixLen(e *aclEntry) int {
+	ones, _ := e.network.Mask.Size()
+	return ones
+}
+
+func ruleActive(rule *models.AccessRule, now time.Time) bool {
+	return rule.ExpiresAt == nil || now.Before(*rule.ExpiresAt)
+}
+
+// ListRules 获取全部规则（管理接口）
+func (s *AccessListService) ListRules() ([]models.AccessRule, error) {
+	return s.rules.List()
+}
+
+// AddRule 新增或更新规则（管理接口）
+func (s *AccessListService) AddRule(ctx context.Context, rule *models.AccessRule) error {
+	if err := normalizeAccessRule(rule); err != nil {
+		return err
+	}
+
+	// 规则已存在时为更新，审计日志记录更新前的快照
+	before, err := s.rules.FindByValue(rule.List, rule.Kind, rule.Value)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if err := s.rules.Upsert(rule); err != nil {
+		return err
+	}
+	audit.Record(ctx, "acl.upsert", "access_rule", rule.ID, before, rule)
+
+	if err := s.cacheRule(rule); err != nil {
+		return err
+	}
+	return s.notify()
+}
+
+// RemoveRule 删除规则（管理接口）
+func (s *AccessListService) RemoveRule(ctx context.Context, id uint) error {
+	rule, err := s.rules.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := s.rules.Delete(id); err != nil {
+		return err
+	}
+	audit.Record(ctx, "acl.remove", "access_rule", id, rule, nil)
+	if err := cache.HDel(s.cfg.Seckill.ACLKey, aclField(rule)); err != nil {
+		return err
+	}
+	return s.notify()
+}
+
+func (s *AccessListService) cacheRule(rule *models.AccessRule) error {
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return err
+	}
+	return cache.HSet(s.cfg.Seckill.ACLKey, aclField(rule), data)
+}
+
+// notify 通知所有实例刷新本地快照，本实例同步刷新
+func (s *AccessListService) notify() error {
+	if err := cache.Publish(s.cfg.Seckill.ACLChannel, "reload"); err != nil {
+		log.Printf("Failed to publish access list change: %v", err)
+	}
+	return s.Reload()
+}
+
+func aclField(rule *models.AccessRule) string {
+	return fmt.Sprintf("%s:%s:%s", rule.List, rule.Kind, rule.Value)
+}
+
+func blockReason(prefix, reason string) string {
+	if reason == "" {
+		return prefix
+	}
+	return fmt.Sprintf("%s: %s", prefix, reason)
+}
+
+// normalizeAccessRule 校验规则，单个IP转换为/32或/128网段
+func normalizeAccessRule(rule *models.AccessRule) error {
+	if rule.List != models.AccessListBlock && rule.List != models.AccessListAllow {
+		return fmt.Errorf("%w: list must be %q or %q", ErrInvalidAccessRule, models.AccessListBlock, models.AccessListAllow)
+	}
+
+	rule.Value = strings.TrimSpace(rule.Value)
+	if rule.Value == "" {
+		return fmt.Errorf("%w: value is required", ErrInvalidAccessRule)
+	}
+
+	switch rule.Kind {
+	case models.AccessKindUser:
+	case models.AccessKindIP:
+		_, network, err := parseCIDR(rule.Value)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAccessRule, err)
+		}
+		rule.Value = network.String()
+	default:
+		return fmt.Errorf("%w: kind must be %q or %q", ErrInvalidAccessRule, models.AccessKindUser, models.AccessKindIP)
+	}
+
+	if rule.ExpiresAt != nil && !rule.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAccessRule)
+	}
+	return nil
+}
+
+func parseCIDR(value string) (net.IP, *net.IPNet, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid ip %q", value)
+		}
+		if ip.To4() != nil {
+			value += "/32"
+		} else {
+			value += "/128"
+		}
+	}
+	return net.ParseCIDR(value)
+}
+
+func buildACLSnapshot(rules []models.AccessRule) *aclSnapshot {
+	snapshot := &aclSnapshot{
+		blockedUsers: make(map[string]*aclEntry),
+		allowedUsers: make(map[string]*aclEntry),
+	}
+
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+		entry := &aclEntry{reason: rule.Reason, expiresAt: rule.ExpiresAt}
+		if !entry.active(now) {
+			continue
+		}
+
+		switch rule.Kind {
+		case models.AccessKindUser:
+			if rule.List == models.AccessListAllow {
+				snapshot.allowedUsers[rule.Value] = entry
+			} else {
+				snapshot.blockedUsers[rule.Value] = entry
+			}
+		case models.AccessKindIP:
+			_, network, err := parseCIDR(rule.Value)
+			if err != nil {
+				continue
+			}
+			entry.network = network
+			if rule.List == models.AccessListAllow {
+				snapshot.allowedNets = append(snapshot.allowedNets, entry)
+			} else {
+				snapshot.blockedNets = append(snapshot.blockedNets, entry)
+			}
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-seckill/audit"
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/models"
+)
+
+func newTestAccessList(t *testing.T) (*AccessListService, *config.Config) {
+	t.Helper()
+	_, repos, _ := newTestService(t)
+	cfg := config.Load()
+	return NewAccessListService(cfg, repos.AccessRules), cfg
+}
+
+func addRule(t *testing.T, s *AccessListService, list, kind, value string) {
+	t.Helper()
+	if err := s.AddRule(context.Background(), &models.AccessRule{List: list, Kind: kind, Value: value}); err != nil {
+		t.Fatalf("AddRule(%s %s %s): %v", list, kind, value, err)
+	}
+}
+
+func TestAccessListPrecedence(t *testing.T) {
+	s, _ := newTestAccessList(t)
+	addRule(t, s, models.AccessListBlock, models.AccessKindUser, "bad")
+	addRule(t, s, models.AccessListAllow, models.AccessKindUser, "vip")
+	addRule(t, s, models.AccessListAllow, models.AccessKindIP, "10.0.0.0/8")
+	addRule(t, s, models.AccessListBlock, models.AccessKindIP, "10.1.0.0/16")
+	addRule(t, s, models.AccessListAllow, models.AccessKindIP, "10.1.2.3")
+	addRule(t, s, models.AccessListBlock, models.AccessKindIP, "192.168.0.0/16")
+	addRule(t, s, models.AccessListBlock, models.AccessKindIP, "172.16.0.0/12")
+	addRule(t, s, models.AccessListAllow, models.AccessKindIP, "172.16.0.0/12")
+
+	for _, tc := range []struct {
+		name, user, ip string
+		allowed        bool
+	}{
+		{"user block beats ip allow", "bad", "10.0.0.1", false},
+		{"user allow beats ip block", "vip", "192.168.1.1", true},
+		{"ip allow", "u1", "10.2.0.1", true},
+		{"narrower ip block beats wider allow", "u1", "10.1.0.1", false},
+		{"narrower ip allow beats wider block", "u1", "10.1.2.3", true},
+		{"ip block", "u1", "192.168.1.1", false},
+		{"block wins on the same network", "u1", "172.16.0.1", false},
+		{"no rule", "u1", "8.8.8.8", true},
+	} {
+		if allowed, reason := s.Check(tc.user, tc.ip); allowed != tc.allowed {
+			t.Errorf("%s: Check(%s, %s) = %v (%s); want %v", tc.name, tc.user, tc.ip, allowed, reason, tc.allowed)
+		}
+	}
+}
+
+func TestAccessListUpsertAuditsPreviousRule(t *testing.T) {
+	s, _ := newTestAccessList(t)
+	addRule(t, s, models.AccessListBlock, models.AccessKindUser, "bad")
+
+	entry := &models.AuditLog{}
+	ctx := audit.NewContext(context.Background(), entry)
+	if err := s.AddRule(ctx, &models.AccessRule{List: models.AccessListBlock, Kind: models.AccessKindUser, Value: "bad", Reason: "fraud"}); err != nil {
+		t.Fatalf("AddRule: %v", err)
+	}
+	var before models.AccessRule
+	if err := json.Unmarshal([]byte(entry.Before), &before); err != nil || before.Value != "bad" || before.Reason != "" {
+		t.Errorf("audit before = %q, %v; want the rule before the update", entry.Before, err)
+	}
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(entry.Diff), &diff); err != nil || diff["reason"]["after"] != "fraud" || diff["value"] != nil {
+		t.Errorf("audit diff = %q, %v; want only the changed fields", entry.Diff, err)
+	}
+}
+
+func TestAccessListReloadSkipsExpiredRules(t *testing.T) {
+	_, repos, _ := newTestService(t)
+	cfg := config.Load()
+	s := NewAccessListService(cfg, repos.AccessRules)
+
+	expired := time.Now().Add(-time.Minute)
+	active := time.Now().Add(time.Hour)
+	for _, rule := range []*models.AccessRule{
+		{List: models.AccessListBlock, Kind: models.AccessKindUser, Value: "expired", ExpiresAt: &expired},
+		{List: models.AccessListBlock, Kind: models.AccessKindUser, Value: "active", ExpiresAt: &active},
+	} {
+		if err := repos.AccessRules.Upsert(rule); err != nil {
+			t.Fatalf("Upsert: %v", err)
+		}
+	}
+
+	// Redis副本为空时从数据库恢复，过期规则不写回Redis
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	fields, err := cache.HGetAll(cfg.Seckill.ACLKey)
+	if err != nil || len(fields) != 1 {
+		t.Errorf("cached rules = %v, %v; want only the active rule", fields, err)
+	}
+	if allowed, _ := s.Check("expired", ""); !allowed {
+		t.Error("expired rule still blocks")
+	}
+	if allowed, _ := s.Check("active", ""); allowed {
+		t.Error("active rule does not block")
+	}
+}
+
+func TestAccessListReloadsOnPublish(t *testing.T) {
+	_, repos, _ := newTestService(t)
+	writer := NewAccessListService(config.Load(), repos.AccessRules)
+	// 兜底刷新间隔为0时不启动定时刷新，只依赖变更通知
+	cfg := config.Load()
+	cfg.Seckill.ACLRefreshInterval = 0
+	reader := NewAccessListService(cfg, repos.AccessRules)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := reader.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	addRule(t, writer, models.AccessListBlock, models.AccessKindUser, "bad")
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if allowed, _ := reader.Check("bad", ""); !allowed {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reader did not reload after the rule change was published")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
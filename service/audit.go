@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+type AuditService struct {
+	logs repository.AuditRepository
+}
+
+func NewAuditService(logs repository.AuditRepository) *AuditService {
+	return &AuditService{logs: logs}
+}
+
+// Create 写入审计日志，供审计中间件调用
+func (s *AuditService) Create(entry *models.AuditLog) error {
+	return s.logs.Create(entry)
+}
+
+// ListAuditLogs 按时间倒序分页查询审计日志，filter中的游标和数量由cursor、limit决定
+func (s *AuditService) ListAuditLogs(filter repository.AuditFilter, cursor string, limit int) ([]models.AuditLog, string, error) {
+	if cursor != "" {
+		parts, err := decodeCursor(cursor, 1)
+		if err != nil {
+			return nil, "", err
+		}
+		id, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		filter.BeforeID = uint(id)
+	}
+	filter.Limit = limit + 1
+
+	entries, err := s.logs.List(filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		next = encodeCursor(strconv.FormatUint(uint64(entries[len(entries)-1].ID), 10))
+	}
+	return entries, next, nil
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+// BundleItem 组合购商品及数量
+type BundleItem struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity"`
+}
+
+// bundleScript 组合购库存扣减脚本：令牌有效且所有商品库存充足时一起扣减、记录流水并删除令牌，否则全部不扣
+// KEYS: 库存键 * n，用户下单标记键 * n，库存流水键 * n，令牌键
+// ARGV: n，订单号，购买数量 * n，令牌的值，流水保留条数
+var bundleScript = cache.RegisterScript("seckill.bundle_decrement", `
+	local n = tonumber(ARGV[1])
+	local orderNo = ARGV[2]
+
+	if redis.call('get', KEYS[3 * n + 1]) ~= ARGV[n + 3] then
+		return {0, -1}
+	end
+
+	for i = 1, n do
+		local stock = tonumber(redis.call('get', KEYS[i]) or 0)
+		if stock < tonumber(ARGV[i + 2]) then
+			return {0, i}
+		end
+	end
+
+	for i = 1, n do
+		local balance = redis.call('decrby', KEYS[i], ARGV[i + 2])
+		redis.call('setex', KEYS[n + i], 3600, orderNo)
+		redis.call('xadd', KEYS[2 * n + i], 'MAXLEN', '~', ARGV[n + 4], '*', 'reason', 'decrement', 'delta', -tonumber(ARGV[i + 2]),
+			'balance', balance, 'correlation_id', orderNo, 'note', 'bundle')
+	end
+	redis.call('del', KEYS[3 * n + 1])
+
+	local now = redis.call('time')
+	return {1, 0, now[1], now[2]}
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	orderNo := args[1]
+	if ok, err := checkToken(tx, keys[3*n], args[n+2]); !ok {
+		return []interface{}{0, tokenRejected}, err
+	}
+	maxLen, err := strconv.ParseInt(args[n+3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	quantities := make([]int64, n)
+	for i := 0; i < n; i++ {
+		if quantities[i], err = strconv.ParseInt(args[i+2], 10, 64); err != nil {
+			return nil, err
+		}
+		stock, err := tx.GetInt(keys[i])
+		if err != nil {
+			return nil, err
+		}
+		if stock < quantities[i] {
+			return []interface{}{0, i + 1}, nil
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		balance, err := tx.IncrBy(keys[i], -quantities[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Set(keys[n+i], orderNo, time.Hour); err != nil {
+			return nil, err
+		}
+		if _, err := tx.XAddMaxLen(keys[2*n+i], maxLen, map[string]interface{}{
+			"reason": "decrement", "delta": -quantities[i], "balance": balance, "correlation_id": orderNo, "note": "bundle",
+		}); err != nil {
+			return nil, err
+		}
+	}
+	tx.Del(keys[3*n])
+	return decrementSuccess(tx), nil
+})
+
+// normalizeBundleItems 校验组合购明细，数量默认为1且不能超过maxQuantity，同一商品只能出现一次
+func normalizeBundleItems(items []BundleItem, maxQuantity int) ([]BundleItem, error) {
+	if len(items) == 0 {
+		return nil, errors.New("bundle items required")
+	}
+
+	seen := make(map[uint]bool, len(items))
+	normalized := make([]BundleItem, 0, len(items))
+	for _, item := range items {
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 || item.Quantity > maxQuantity {
+			return nil, fmt.Errorf("invalid quantity for product %d: must be between 1 and %d", item.ProductID, maxQuantity)
+		}
+		if seen[item.ProductID] {
+			return nil, fmt.Errorf("duplicate product %d in bundle", item.ProductID)
+		}
+		seen[item.ProductID] = true
+		normalized = append(normalized, item)
+	}
+	return normalized, nil
+}
+
+// GenerateBundleToken 生成组合购令牌，组合中的每个商品都要满足秒杀时间、预约和购买资格要求
+func (s *SeckillService) GenerateBundleToken(userID string, items []BundleItem) (string, error) {
+	items, err := normalizeBundleItems(items, s.cfg.Seckill.BundleMaxQuantity)
+	if err != nil {
+		return "", err
+	}
+
+	products := make([]*models.Product, len(items))
+	for i, item := range items {
+		if products[i], err = s.loadProduct(item.ProductID); err != nil {
+			return "", fmt.Errorf("product %d not found", item.ProductID)
+		}
+	}
+	tag, err := bundleTag(products)
+	if err != nil {
+		return "", err
+	}
+
+	for i, item := range items {
+		if err := s.checkPurchasable(userID, products[i]); err != nil {
+			return "", fmt.Errorf("product %d: %w", item.ProductID, err)
+		}
+		stock, err := s.redisStock(products[i])
+		if err != nil || stock < int64(item.Quantity) {
+			return "", fmt.Errorf("product %d out of stock", item.ProductID)
+		}
+	}
+
+	token := fmt.Sprintf("%s-bundle-%d", userID, time.Now().UnixNano())
+	if err := cache.Set(s.tokenKey(tag, token), bundleTokenOwner(userID, items), time.Duration(s.cfg.Seckill.TokenExpire)*time.Second); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// bundleTag 组合购的哈希标签。扣减脚本访问的键必须在同一个槽，只有同一库存分组的商品才能组合购买
+func bundleTag(products []*models.Product) (string, error) {
+	tag := inventoryTag(products[0])
+	for _, product := range products[1:] {
+		if inventoryTag(product) != tag {
+			return "", fmt.Errorf("products %d and %d are not in the same inventory group", products[0].ID, product.ID)
+		}
+	}
+	return tag, nil
+}
+
+// bundleTokenOwner 组合购令牌的值，记录令牌签发给哪个用户以什么数量购买哪些商品，与商品顺序无关
+// 下单时数量与领取令牌时不同会被扣减脚本拒绝，不能领取少量的令牌后买走更多库存
+func bundleTokenOwner(userID string, items []BundleItem) string {
+	sorted := append([]BundleItem(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ProductID < sorted[j].ProductID })
+
+	parts := make([]string, len(sorted))
+	for i, item := range sorted {
+		parts[i] = fmt.Sprintf("%dx%d", item.ProductID, item.Quantity)
+	}
+	return fmt.Sprintf("%s:bundle:%s", userID, strings.Join(parts, ","))
+}
+
+// SeckillBundle 组合购秒杀：一个Lua脚本原子扣减多个商品库存，生成一个带明细的父订单
+// 令牌必须由GenerateBundleToken为该用户和这组商品签发，扣减成功时在同一脚本中删除
+func (s *SeckillService) SeckillBundle(userID string, items []BundleItem, token string) (*models.Order, error) {
+	items, err := normalizeBundleItems(items, s.cfg.Seckill.BundleMaxQuantity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 所有商品都必须处于秒杀时间内
+	products := make([]*models.Product, len(items))
+	for i, item := range items {
+		if products[i], err = s.loadProduct(item.ProductID); err != nil {
+			return nil, fmt.Errorf("product %d not found", item.ProductID)
+		}
+		if !utils.IsSeckillTime(products[i].StartTime, products[i].EndTime) {
+			return nil, fmt.Errorf("seckill of product %d not started or ended", item.ProductID)
+		}
+	}
+	tag, err := bundleTag(products)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(items)
+	keys := make([]string, 0, 3*n+1)
+	args := make([]interface{}, 0, n+3)
+	orderNo := s.newOrderNo(userID)
+	args = append(args, n, orderNo)
+	for i, item := range items {
+		keys = append(keys, s.stockKey(products[i]))
+		args = append(args, item.Quantity)
+	}
+	for _, product := range products {
+		keys = append(keys, s.orderKey(product, userID))
+	}
+	for _, product := range products {
+		keys = append(keys, s.ledgerKey(product))
+	}
+	keys = append(keys, s.tokenKey(tag, token))
+	args = append(args, bundleTokenOwner(userID, items), s.ledgerMaxLen())
+
+	var reply decrementReply
+	if err := bundleScript.Run(keys, args...).Scan(&reply); err != nil {
+		return nil, fmt.Errorf("seckill failed: %w", err)
+	}
+	if !reply.OK {
+		if reply.FailedIndex == tokenRejected {
+			return nil, errors.New("invalid token")
+		}
+		if reply.FailedIndex >= 1 && reply.FailedIndex <= n {
+			return nil, fmt.Errorf("product %d out of stock", items[reply.FailedIndex-1].ProductID)
+		}
+		return nil, errors.New("out of stock")
+	}
+	decrementedAt := reply.at()
+
+	// 按扣减成功时刻计算每个商品的成交价
+	total := decimal.Zero
+	names := make([]string, 0, n)
+	orderItems := make([]models.OrderItem, 0, n)
+	for i, item := range items {
+		price, _ := s.priceAt(products[i], decrementedAt)
+		total = total.Add(decimal.NewFromFloat(price).Mul(decimal.NewFromInt(int64(item.Quantity))))
+		names = append(names, products[i].Name)
+		orderItems = append(orderItems, models.OrderItem{
+			ProductID:   item.ProductID,
+			ProductName: products[i].Name,
+			Price:       price,
+			Quantity:    item.Quantity,
+		})
+	}
+	totalPrice, _ := total.Round(2).Float64()
+
+	order := &models.Order{
+		OrderNo:     orderNo,
+		UserID:      userID,
+		ProductID:   items[0].ProductID,
+		ProductName: strings.Join(names, " + "),
+		Price:       totalPrice,
+		Status:      models.OrderStatusPending,
+		Items:       orderItems,
+	}
+
+	// 使用分布式锁保护数据库写入
+	lockKey := fmt.Sprintf("%sorder:%s", s.cfg.Seckill.LockPrefix, orderNo)
+	lock := utils.NewDistributedLock(lockKey, 5*time.Second)
+
+	lockCtx, cancel := context.WithTimeout(context.Background(), orderLockWait)
+	defer cancel()
+	if err := lock.LockContext(lockCtx); err != nil {
+		s.rollbackBundle(userID, orderNo, products, items)
+		return nil, errors.New("failed to acquire lock")
+	}
+	defer lock.Unlock()
+	lost := lock.StartWatchdog(context.Background())
+
+	// 父订单和明细在同一事务中写入
+	if err := s.orders.Create(order); err != nil {
+		log.Printf("Failed to create bundle order: %v", err)
+		s.rollbackBundle(userID, orderNo, products, items)
+		return nil, errors.New("failed to create order")
+	}
+	s.markWritten("order", order.OrderNo)
+	s.invalidateEligibility(userID)
+	warnIfLockLost(lost, lockKey)
+
+	return order, nil
+}
+
+// rollbackBundle 回滚组合购扣减的库存和下单标记
+func (s *SeckillService) rollbackBundle(userID, orderNo string, products []*models.Product, items []BundleItem) {
+	for i, item := range items {
+		s.moveStock(products[i], "incr", int64(item.Quantity), LedgerReasonRollback, orderNo, "order creation failed")
+		cache.Del(s.orderKey(products[i], userID))
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"sync"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// EligibilityContext 资格校验上下文
+type EligibilityContext struct {
+	UserID  string
+	Product *models.Product
+	Now     time.Time
+
+	Orders repository.OrderRepository
+	Users  repository.UserProfileRepository
+}
+
+// EligibilityRule 购买资格规则
+type EligibilityRule interface {
+	Name() string
+	// Check 校验用户资格，通过时返回空字符串，否则返回拒绝原因
+	Check(ec *EligibilityContext) (string, error)
+}
+
+// RuleFactory 根据规则参数构造规则
+type RuleFactory func(params json.RawMessage) (EligibilityRule, error)
+
+// IneligibleError 用户不满足购买资格
+type IneligibleError struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+func (e *IneligibleError) Error() string {
+	return fmt.Sprintf("not eligible: %s", e.Reason)
+}
+
+var (
+	ruleFactoriesMu sync.RWMutex
+	ruleFactories   = map[string]RuleFactory{
+		"new_user":        newNewUserRule,
+		"membership_tier": newMembershipTierRule,
+		"no_recent_win":   newNoRecentWinRule,
+	}
+)
+
+// RegisterEligibilityRule 注册自定义资格规则，可与规则构造并发调用
+func RegisterEligibilityRule(name string, factory RuleFactory) {
+	ruleFactoriesMu.Lock()
+	defer ruleFactoriesMu.Unlock()
+	ruleFactories[name] = factory
+}
+
+// BuildEligibilityRules 根据配置构造规则列表
+func BuildEligibilityRules(configs []models.RuleConfig) ([]EligibilityRule, error) {
+	rules := make([]EligibilityRule, 0, len(configs))
+	for _, rc := range configs {
+		ruleFactoriesMu.RLock()
+		factory, ok := ruleFactories[rc.Name]
+		ruleFactoriesMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown eligibility rule %q", rc.Name)
+		}
+		rule, err := factory(rc.Params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid params for rule %q: %w", rc.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// eligibilityResult 缓存的资格校验结果
+type eligibilityResult struct {
+	Passed bool   `json:"passed"`
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// ExpiresAt 结果的过期时间，同一用户的结果保存在一个哈希中，哈希的过期时间会随写入刷新
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// CheckEligibility 按商品配置的规则校验用户购买资格，结果按用户和商品缓存
+// 用户下单或订单状态变化后调用invalidateEligibility清除该用户的全部结果
+func (s *SeckillService) CheckEligibility(userID string, product *models.Product) error {
+	if len(product.EligibilityRules) == 0 {
+		return nil
+	}
+
+	key, field := s.eligibilityKey(userID), eligibilityField(product)
+	if cached, err := cache.HGet(key, field); err == nil {
+		var result eligibilityResult
+		if json.Unmarshal([]byte(cached), &result) == nil && time.Now().Unix() < result.ExpiresAt {
+			return result.err()
+		}
+	}
+
+	rules, err := BuildEligibilityRules(product.EligibilityRules)
+	if err != nil {
+		return err
+	}
+
+	ec := &EligibilityContext{
+		UserID:  userID,
+		Product: product,
+		Now:     time.Now(),
+		Orders:  s.orders,
+		Users:   s.users,
+	}
+	result := eligibilityResult{Passed: true}
+	for _, rule := range rules {
+		reason, err := rule.Check(ec)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			result = eligibilityResult{Rule: rule.Name(), Reason: reason}
+			break
+		}
+	}
+
+	ttl := time.Duration(s.cfg.Seckill.EligibilityCacheTTL) * time.Second
+	result.ExpiresAt = ec.Now.Add(ttl).Unix()
+	if data, err := json.Marshal(result); err == nil {
+		cache.HSetWithExpire(key, map[string]interface{}{field: data}, ttl)
+	}
+	return result.err()
+}
+
+// invalidateEligibility 清除用户的资格校验缓存，下单后新用户、近期中签等规则的结果会变化
+func (s *SeckillService) invalidateEligibility(userID string) {
+	if err := cache.Del(s.eligibilityKey(userID)); err != nil {
+		log.Printf("Failed to invalidate eligibility cache of user %s: %v", userID, err)
+	}
+}
+
+func (r eligibilityResult) err() error {
+	if r.Passed {
+		return nil
+	}
+	return &IneligibleError{Rule: r.Rule, Reason: r.Reason}
+}
+
+// eligibilityKey 同一用户在各商品上的校验结果保存在一个哈希中，便于下单后一起清除
+func (s *SeckillService) eligibilityKey(userID string) string {
+	return s.cfg.Seckill.EligiblePrefix + userID
+}
+
+// eligibilityField 哈希字段包含规则配置的校验和，规则变更后旧结果自动失效
+func eligibilityField(product *models.Product) string {
+	data, _ := json.Marshal(product.EligibilityRules)
+	return fmt.Sprintf("%d:%08x", product.ID, crc32.ChecksumIEEE(data))
+}
+
+// newUserRule 仅允许从未成功下单的新用户购买
+type newUserRule struct{}
+
+func newNewUserRule(params json.RawMessage) (EligibilityRule, error) {
+	return newUserRule{}, nil
+}
+
+func (newUserRule) Name() string { return "new_user" }
+
+func (newUserRule) Check(ec *EligibilityContext) (string, error) {
+	count, err := ec.Orders.CountActiveByUser(ec.UserID, time.Time{})
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "only available to new users", nil
+	}
+	return "", nil
+}
+
+// membershipTierRule 仅允许指定会员等级的用户购买
+type membershipTierRule struct {
+	Tiers []string `json:"tiers"`
+}
+
+func newMembershipTierRule(params json.RawMessage) (EligibilityRule, error) {
+	var rule membershipTierRule
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &rule); err != nil {
+			return nil, err
+		}
+	}
+	if len(rule.Tiers) == 0 {
+		return nil, errors.New("tiers is required")
+	}
+	return &rule, nil
+}
+
+func (r *membershipTierRule) Name() string { return "membership_tier" }
+
+func (r *membershipTierRule) Check(ec *EligibilityContext) (string, error) {
+	profile, err := ec.Users.FindByUserID(ec.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Sprintf("membership tier %v required", r.Tiers), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, tier := range r.Tiers {
+		if profile.Tier == tier {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("membership tier %v required", r.Tiers), nil
+}
+
+// noRecentWinRule 最近N天内成功秒杀过的用户不能购买
+type noRecentWinRule struct {
+	Days int `json:"days"`
+}
+
+func newNoRecentWinRule(params json.RawMessage) (EligibilityRule, error) {
+	rule := noRecentWinRule{Days: 30}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &rule); err != nil {
+			return nil, err
+		}
+	}
+	if rule.Days <= 0 {
+		return nil, errors.New("days must be positive")
+	}
+	return &rule, nil
+}
+
+func (r *noRecentWinRule) Name() string { return "no_recent_win" }
+
+func (r *noRecentWinRule) Check(ec *EligibilityContext) (string, error) {
+	since := ec.Now.AddDate(0, 0, -r.Days)
+	count, err := ec.Orders.CountActiveByUser(ec.UserID, since)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return fmt.Sprintf("already won a seckill in the last %d days", r.Days), nil
+	}
+	return "", nil
+}
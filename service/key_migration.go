@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// legacyInventoryKeys 旧版本的库存键和库存流水键前缀，前缀后直接拼商品ID
+var legacyInventoryKeys = []struct{ stock, ledger string }{
+	{"seckill:{inventory}:stock:", "seckill:{inventory}:ledger:"},
+	{"seckill:stock:", "seckill:ledger:"},
+}
+
+// KeyMigrationResult 库存键迁移结果
+type KeyMigrationResult struct {
+	// Moved 已迁移的键，格式为 旧键 -> 新键
+	Moved []string
+	// Conflicts 新键已存在而未迁移的旧键，需要人工确认后处理
+	Conflicts []string
+}
+
+// MigrateInventoryKeys 把旧版本的库存键和库存流水迁移到带商品哈希标签的新键，包括已删除的商品
+// 下单标记和令牌不迁移：下单标记过期后由数据库兜底判断，旧令牌失效后用户重新领取即可
+func (s *SeckillService) MigrateInventoryKeys() (*KeyMigrationResult, error) {
+	result := &KeyMigrationResult{}
+	filter := repository.ProductFilter{Limit: 500}
+	for {
+		products, err := s.products.List(filter, repository.WithDeleted())
+		if err != nil {
+			return result, err
+		}
+		for i := range products {
+			if err := s.migrateProductKeys(&products[i], result); err != nil {
+				return result, err
+			}
+		}
+		if len(products) < filter.Limit {
+			return result, nil
+		}
+		filter.AfterID = products[len(products)-1].ID
+	}
+}
+
+func (s *SeckillService) migrateProductKeys(product *models.Product, result *KeyMigrationResult) error {
+	for _, legacy := range legacyInventoryKeys {
+		moves := [][2]string{
+			{fmt.Sprintf("%s%d", legacy.stock, product.ID), s.stockKey(product)},
+			{fmt.Sprintf("%s%d", legacy.ledger, product.ID), s.ledgerKey(product)},
+		}
+		for _, move := range moves {
+			moved, err := cache.Move(move[0], move[1])
+			switch {
+			case errors.Is(err, cache.ErrKeyExists):
+				result.Conflicts = append(result.Conflicts, move[0])
+			case err != nil:
+				return fmt.Errorf("move %s: %w", move[0], err)
+			case moved:
+				result.Moved = append(result.Moved, move[0]+" -> "+move[1])
+			}
+		}
+	}
+	return nil
+}
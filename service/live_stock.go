@@ -0,0 +1,89 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// MaxLiveStockIDs 单次批量查询实时库存的最大商品数
+const MaxLiveStockIDs = 100
+
+// GetLiveStocks 批量查询商品的实时剩余库存，一次MGET读取全部商品的Redis库存
+// 重复的ID只返回一次，不存在的商品被忽略
+func (s *SeckillService) GetLiveStocks(productIDs []uint) ([]models.LiveStock, error) {
+	if len(productIDs) == 0 {
+		return nil, fmt.Errorf("%w: ids required", ErrInvalidQuery)
+	}
+	if len(productIDs) > MaxLiveStockIDs {
+		return nil, fmt.Errorf("%w: at most %d ids", ErrInvalidQuery, MaxLiveStockIDs)
+	}
+
+	seen := make(map[uint]bool, len(productIDs))
+	products := make([]models.Product, 0, len(productIDs))
+	for _, id := range productIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		product, err := s.loadProduct(id)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, *product)
+	}
+
+	if err := s.attachLiveStock(products); err != nil {
+		return nil, err
+	}
+	stocks := make([]models.LiveStock, len(products))
+	for i := range products {
+		stocks[i] = *products[i].LiveStock
+	}
+	return stocks, nil
+}
+
+// attachLiveStock 一次MGET读取商品的Redis库存，写入LiveStock字段，库存键可以分布在不同的槽
+// 库存键不存在（未预热或已过期）时按0计算，与扣减脚本的判断一致
+func (s *SeckillService) attachLiveStock(products []models.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	keys := make([]string, len(products))
+	for i := range products {
+		keys[i] = s.stockKey(&products[i])
+	}
+	values, err := cache.MGet(keys...)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		var remaining int64
+		if v, ok := values[i].(string); ok {
+			remaining, _ = strconv.ParseInt(v, 10, 64)
+		}
+		products[i].LiveStock = newLiveStock(products[i].ID, remaining, products[i].SeckillStock)
+	}
+	return nil
+}
+
+// newLiveStock 计算剩余百分比，管理员调增库存后剩余量可能超过秒杀库存，百分比最多为100
+func newLiveStock(productID uint, remaining int64, total int) *models.LiveStock {
+	if remaining < 0 {
+		remaining = 0
+	}
+	stock := &models.LiveStock{ProductID: productID, Remaining: remaining, SoldOut: remaining == 0}
+	if total > 0 {
+		stock.RemainingPercent = math.Min(100, math.Round(float64(remaining)*10000/float64(total))/100)
+	}
+	return stock
+}
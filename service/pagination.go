@@ -0,0 +1,131 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// ErrInvalidQuery 列表查询参数或游标无效
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ProductListQuery 商品列表查询参数
+type ProductListQuery struct {
+	Status string
+	From   time.Time
+	To     time.Time
+	Name   string
+	Cursor string
+	Limit  int
+}
+
+// encodeCursor 将分页位置编码为不透明的游标字符串
+func encodeCursor(parts ...string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+func decodeCursor(cursor string, n int) ([]string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != n {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	return parts, nil
+}
+
+// ListProducts 分页获取商品列表，返回下一页游标，没有更多数据时游标为空
+// Limit为0时不分页，返回全部符合条件的商品
+func (s *SeckillService) ListProducts(query ProductListQuery) ([]models.Product, string, error) {
+	switch query.Status {
+	case "", models.ProductStatusUpcoming, models.ProductStatusLive, models.ProductStatusEnded:
+	default:
+		return nil, "", fmt.Errorf("%w: unknown status %q", ErrInvalidQuery, query.Status)
+	}
+
+	now := time.Now()
+	filter := repository.ProductFilter{
+		Status: query.Status,
+		Now:    now,
+		From:   query.From,
+		To:     query.To,
+		Name:   query.Name,
+	}
+	if query.Limit > 0 {
+		filter.Limit = query.Limit + 1
+	}
+	if query.Cursor != "" {
+		parts, err := decodeCursor(query.Cursor, 1)
+		if err != nil {
+			return nil, "", err
+		}
+		id, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		filter.AfterID = uint(id)
+	}
+
+	products, err := s.products.List(filter, repository.FromReplica())
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if query.Limit > 0 && len(products) > query.Limit {
+		products = products[:query.Limit]
+		next = encodeCursor(strconv.FormatUint(uint64(products[len(products)-1].ID), 10))
+	}
+	for i := range products {
+		s.applyCurrentPrice(&products[i], now)
+	}
+	// 实时库存读取失败时仍返回列表，只是不带live_stock
+	if err := s.attachLiveStock(products); err != nil {
+		log.Printf("Failed to load live stock for product list: %v", err)
+	}
+	return products, next, nil
+}
+
+// ListUserOrders 按下单时间倒序分页获取用户订单，只查询用户所在的订单分片
+func (s *SeckillService) ListUserOrders(userID, status, cursor string, limit int) ([]models.Order, string, error) {
+	query := repository.OrderQuery{
+		UserID: userID,
+		Status: status,
+		Limit:  limit + 1,
+	}
+	if cursor != "" {
+		parts, err := decodeCursor(cursor, 3)
+		if err != nil {
+			return nil, "", err
+		}
+		nanos, err1 := strconv.ParseInt(parts[0], 10, 64)
+		shard, err2 := strconv.Atoi(parts[1])
+		id, err3 := strconv.ParseUint(parts[2], 10, 32)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, "", fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+		}
+		query.Before = &repository.OrderCursor{CreatedAt: time.Unix(0, nanos), Shard: shard, ID: uint(id)}
+	}
+
+	orders, err := s.orders.Query(query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(orders) > limit {
+		orders = orders[:limit]
+		last := repository.NewOrderCursor(&orders[len(orders)-1], s.cfg.Database.OrderShards)
+		next = encodeCursor(strconv.FormatInt(last.CreatedAt.UnixNano(), 10), strconv.Itoa(last.Shard), strconv.FormatUint(uint64(last.ID), 10))
+	}
+	return orders, next, nil
+}
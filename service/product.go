@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-seckill/audit"
+	"go-seckill/cache"
+	"go-seckill/models"
+)
+
+// ErrProductStarted 商品已开售，修改价格、库存或删除需要force
+var ErrProductStarted = errors.New("product sale has started")
+
+// productFields 商品编辑接口可修改的字段，购买资格规则通过单独的接口维护
+var productFields = []string{
+	"Name", "Price", "Stock", "StartTime", "EndTime", "SeckillStock",
+	"PriceMode", "FloorPrice", "PriceStep", "StepInterval", "RequireReservation", "UpdatedAt",
+}
+
+// UpdateProduct 修改商品信息（管理接口）
+// 开售后修改价格或库存需要force；秒杀库存变化时同步Redis库存：开售前直接重置，开售后按差值调整以保留已售数量
+func (s *SeckillService) UpdateProduct(ctx context.Context, productID uint, update *models.Product, force bool) (*models.Product, error) {
+	current, err := s.products.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	product := *current
+	product.Name = update.Name
+	product.Price = update.Price
+	product.Stock = update.Stock
+	product.StartTime = update.StartTime
+	product.EndTime = update.EndTime
+	product.SeckillStock = update.SeckillStock
+	product.PriceMode = update.PriceMode
+	product.FloorPrice = update.FloorPrice
+	product.PriceStep = update.PriceStep
+	product.StepInterval = update.StepInterval
+	product.RequireReservation = update.RequireReservation
+	// 库存分组决定库存键所在的槽，创建后不能修改
+	if update.InventoryGroup != "" && update.InventoryGroup != current.InventoryGroup {
+		return nil, fmt.Errorf("%w: inventory_group cannot be changed", ErrInvalidProduct)
+	}
+	if err := validateProduct(&product); err != nil {
+		return nil, err
+	}
+
+	started := !time.Now().Before(current.StartTime)
+	if started && !force && pricingOrStockChanged(current, &product) {
+		return nil, fmt.Errorf("%w: changing price or stock requires force", ErrProductStarted)
+	}
+
+	// 开售后先调整Redis库存，剩余库存不足以扣减时不修改数据库
+	delta := int64(product.SeckillStock - current.SeckillStock)
+	correlationID := fmt.Sprintf("product:%d", productID)
+	if started && delta != 0 {
+		if _, err := s.moveStock(&product, "incr", delta, LedgerReasonAdjust, correlationID, "product update"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.products.Update(&product, productFields...); err != nil {
+		if started && delta != 0 {
+			s.moveStock(&product, "incr", -delta, LedgerReasonRollback, correlationID, "product update failed")
+		}
+		return nil, err
+	}
+
+	if !started && delta != 0 {
+		if err := s.PreheatStock(&product, product.SeckillStock); err != nil {
+			return nil, err
+		}
+	}
+	s.invalidateProductCache(productID)
+	audit.Record(ctx, "product.update", "product", productID, current, &product)
+	s.applyCurrentPrice(&product, time.Now())
+	return &product, nil
+}
+
+// pricingOrStockChanged 判断价格、定价参数或库存是否被修改
+func pricingOrStockChanged(a, b *models.Product) bool {
+	return a.Price != b.Price ||
+		a.PriceMode != b.PriceMode ||
+		a.FloorPrice != b.FloorPrice ||
+		a.PriceStep != b.PriceStep ||
+		a.StepInterval != b.StepInterval ||
+		a.Stock != b.Stock ||
+		a.SeckillStock != b.SeckillStock
+}
+
+// DeleteProduct 软删除商品（管理接口），秒杀进行中的商品需要force
+// 删除后Redis库存清零，已发放的令牌无法再扣减库存
+func (s *SeckillService) DeleteProduct(ctx context.Context, productID uint, force bool) error {
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if !force && !now.Before(product.StartTime) && now.Before(product.EndTime) {
+		return fmt.Errorf("%w: deleting a live product requires force", ErrProductStarted)
+	}
+
+	if err := s.products.Delete(productID); err != nil {
+		return err
+	}
+	s.invalidateProductCache(productID)
+	if _, err := s.moveStock(product, "set", 0, LedgerReasonDelete, fmt.Sprintf("product:%d", productID), ""); err != nil {
+		return err
+	}
+	audit.Record(ctx, "product.delete", "product", productID, product, nil)
+	return nil
+}
+
+// RestoreProduct 恢复已删除的商品（管理接口）
+// Redis库存恢复为删除前的余量；流水中找不到删除记录时按秒杀库存减去有效订单数重新计算
+func (s *SeckillService) RestoreProduct(ctx context.Context, productID uint) (*models.Product, error) {
+	product, err := s.products.Restore(productID)
+	if err != nil {
+		return nil, err
+	}
+	// 过滤器重建时不包含已删除的商品
+	s.addToProductFilter(productID)
+	s.invalidateProductCache(productID)
+
+	stock, err := s.stockBeforeDelete(product)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.moveStock(product, "set", stock, LedgerReasonRestore, fmt.Sprintf("product:%d", productID), ""); err != nil {
+		return nil, err
+	}
+	audit.Record(ctx, "product.restore", "product", productID, nil, product)
+	s.applyCurrentPrice(product, time.Now())
+	return product, nil
+}
+
+// stockBeforeDelete 计算商品删除前的Redis库存余量
+func (s *SeckillService) stockBeforeDelete(product *models.Product) (int64, error) {
+	messages, err := cache.XRevRangeN(s.ledgerKey(product), "+", "-", 1)
+	if err != nil {
+		return 0, err
+	}
+	if len(messages) == 1 {
+		if entry := parseLedgerEntry(messages[0].ID, messages[0].Values); entry.Reason == LedgerReasonDelete {
+			return entry.Balance - entry.Delta, nil
+		}
+	}
+
+	sold, err := s.orders.CountActiveByProduct(product.ID)
+	if err != nil {
+		return 0, err
+	}
+	if stock := int64(product.SeckillStock) - sold; stock > 0 {
+		return stock, nil
+	}
+	return 0, nil
+}
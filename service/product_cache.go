@@ -0,0 +1,351 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// productMissingField 负缓存标记字段，哈希中存在该字段表示商品不存在
+const productMissingField = "_missing"
+
+// productCacheExcluded 由服务端实时计算的字段，不写入缓存
+var productCacheExcluded = map[string]bool{"current_price": true, "next_price_drop_at": true, "live_stock": true}
+
+// productFillScript 版本号未变化时回填商品缓存，版本号在失效时递增，
+// 失效前开始的回填读到的是旧数据，版本号已变，不会写回
+// KEYS: 缓存哈希键，版本号键
+// ARGV: 读取数据库前的版本号，过期毫秒数，字段，值，...
+var productFillScript = cache.RegisterScript("product.cache_fill", `
+	if (redis.call('get', KEYS[2]) or '0') ~= ARGV[1] then
+		return 0
+	end
+	redis.call('del', KEYS[1])
+	redis.call('hset', KEYS[1], unpack(ARGV, 3))
+	redis.call('pexpire', KEYS[1], ARGV[2])
+	return 1
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	version, err := tx.Get(keys[1])
+	if errors.Is(err, cache.Nil) {
+		version, err = "0", nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if version != args[0] {
+		return 0, nil
+	}
+	ttl, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{}, len(args)/2)
+	for i := 2; i+1 < len(args); i += 2 {
+		values[args[i]] = args[i+1]
+	}
+	tx.Del(keys[0])
+	if err := tx.HSet(keys[0], values); err != nil {
+		return nil, err
+	}
+	tx.Expire(keys[0], time.Duration(ttl)*time.Millisecond)
+	return 1, nil
+})
+
+// productInvalidateScript 递增版本号并删除缓存哈希
+// KEYS: 缓存哈希键，版本号键
+var productInvalidateScript = cache.RegisterScript("product.cache_invalidate", `
+	redis.call('incr', KEYS[2])
+	redis.call('del', KEYS[1])
+	return 1
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	if _, err := tx.IncrBy(keys[1], 1); err != nil {
+		return nil, err
+	}
+	tx.Del(keys[0])
+	return 1, nil
+})
+
+func (s *SeckillService) productCacheKey(productID uint) string {
+	return fmt.Sprintf("%s{%d}", s.cfg.Seckill.ProductCachePrefix, productID)
+}
+
+// productVersionKey 商品缓存版本号，与缓存哈希在同一个槽
+func (s *SeckillService) productVersionKey(productID uint) string {
+	return fmt.Sprintf("%s{%d}:version", s.cfg.Seckill.ProductCachePrefix, productID)
+}
+
+// loadProduct 读取商品详情，布隆过滤器判断商品不存在时直接返回，否则依次查询进程内缓存、Redis缓存（旁路缓存）和数据库
+// 返回的商品为调用方独占的副本，可以直接修改
+func (s *SeckillService) loadProduct(productID uint) (*models.Product, error) {
+	if !s.productMayExist(productID) {
+		return nil, repository.ErrNotFound
+	}
+
+	key := s.productCacheKey(productID)
+	// 读取Redis前记录本地缓存的失效代数，读取期间发生失效时不写回本地缓存
+	generation := s.localProducts.Generation()
+	if v, ok := s.localProducts.Get(key); ok {
+		// 本地缓存中的nil表示商品不存在
+		cached, _ := v.(*models.Product)
+		if cached == nil {
+			return nil, repository.ErrNotFound
+		}
+		product := *cached
+		return &product, nil
+	}
+
+	product, err := s.loadProductFromRedis(productID)
+	if errors.Is(err, repository.ErrNotFound) {
+		s.localProducts.SetIfGeneration(key, (*models.Product)(nil), generation)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	cached := *product
+	s.localProducts.SetIfGeneration(key, &cached, generation)
+	return product, nil
+}
+
+// loadProductFromRedis 读取Redis中的商品缓存
+// 未命中时同一商品的并发请求合并为一次数据库查询，查询结果回填缓存；不存在的商品写入短时负缓存
+func (s *SeckillService) loadProductFromRedis(productID uint) (*models.Product, error) {
+	key := s.productCacheKey(productID)
+	fields, err := cache.HGetAll(key)
+	if err == nil && len(fields) > 0 {
+		if _, missing := fields[productMissingField]; missing {
+			return nil, repository.ErrNotFound
+		}
+		product, err := decodeProductHash(fields)
+		if err == nil {
+			return product, nil
+		}
+		log.Printf("Failed to decode cached product %d: %v", productID, err)
+	}
+
+	v, err, _ := s.productLoads.Do(key, func() (interface{}, error) {
+		// 查询数据库前读取版本号，查询期间商品被修改时回填会被拒绝
+		version, err := cache.Get(s.productVersionKey(productID))
+		if errors.Is(err, cache.Nil) {
+			version, err = "0", nil
+		}
+		canFill := err == nil
+		if err != nil {
+			log.Printf("Failed to read product cache version %d: %v", productID, err)
+		}
+
+		product, err := s.findProductForCache(productID)
+		if errors.Is(err, repository.ErrNotFound) {
+			if canFill {
+				negativeTTL := time.Duration(s.cfg.Seckill.ProductNegativeTTL) * time.Second
+				s.fillProductCache(productID, version, map[string]interface{}{productMissingField: 1}, negativeTTL)
+			}
+			return nil, err
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if canFill {
+			values, err := encodeProductHash(product)
+			if err != nil {
+				log.Printf("Failed to cache product %d: %v", productID, err)
+			} else {
+				s.fillProductCache(productID, version, values, s.productCacheTTL())
+			}
+		}
+		return product, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// singleflight 的结果由所有等待者共享，返回副本避免互相修改
+	product := *v.(*models.Product)
+	return &product, nil
+}
+
+// findProductForCache 查询回填缓存用的商品，平时读从库；商品刚被创建或修改时读主库，
+// 避免从库复制延迟时把旧数据或负缓存写回。从库查不到时不回退主库，否则不存在的ID每次都要查两次
+func (s *SeckillService) findProductForCache(productID uint) (*models.Product, error) {
+	if s.recentlyWritten("product", strconv.FormatUint(uint64(productID), 10)) {
+		return s.products.FindByID(productID)
+	}
+	return s.products.FindByID(productID, repository.FromReplica())
+}
+
+// fillProductCache 版本号仍为version时写入商品缓存，失败只记录日志
+func (s *SeckillService) fillProductCache(productID uint, version string, values map[string]interface{}, ttl time.Duration) {
+	args := []interface{}{version, ttl.Milliseconds()}
+	for field, value := range values {
+		args = append(args, field, value)
+	}
+	err := productFillScript.Run([]string{s.productCacheKey(productID), s.productVersionKey(productID)}, args...).Err()
+	if err != nil {
+		log.Printf("Failed to cache product %d: %v", productID, err)
+	}
+}
+
+// productCacheTTL 在基础过期时间上附加最多10%的随机抖动，避免同时写入的缓存集中过期
+func (s *SeckillService) productCacheTTL() time.Duration {
+	ttl := time.Duration(s.cfg.Seckill.ProductCacheTTL) * time.Second
+	if jitter := int64(ttl / 10); jitter > 0 {
+		ttl += time.Duration(rand.Int63n(jitter))
+	}
+	return ttl
+}
+
+// BuildProductFilter 将全部未删除商品的ID写入布隆过滤器，服务启动时调用，完成后开始拦截不存在的商品ID
+// 位图只增不减，由多个实例共享，重复构建是幂等的；全部写入后才设置构建完成标记
+func (s *SeckillService) BuildProductFilter() error {
+	if !s.cfg.Seckill.ProductBloomEnabled {
+		return nil
+	}
+	if !s.productFilterBuilding.CompareAndSwap(false, true) {
+		return nil
+	}
+	defer s.productFilterBuilding.Store(false)
+
+	count := 0
+	filter := repository.ProductFilter{Limit: 1000}
+	for {
+		products, err := s.products.List(filter)
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			break
+		}
+		ids := make([]string, len(products))
+		for i := range products {
+			ids[i] = strconv.FormatUint(uint64(products[i].ID), 10)
+		}
+		if err := s.productFilter.Add(ids...); err != nil {
+			return err
+		}
+		count += len(products)
+		filter.AfterID = products[len(products)-1].ID
+	}
+	if err := s.productFilter.MarkBuilt(); err != nil {
+		return err
+	}
+
+	s.productFilterReady.Store(true)
+	log.Printf("Product bloom filter built with %d products (%d bits, %d hashes)",
+		count, s.productFilter.Bits(), s.productFilter.Hashes())
+	return nil
+}
+
+// StartProductFilterRebuild 定期从数据库重建布隆过滤器，
+// 补上未经CreateProduct/RestoreProduct写入数据库的商品，ctx结束后停止
+func (s *SeckillService) StartProductFilterRebuild(ctx context.Context) {
+	interval := time.Duration(s.cfg.Seckill.ProductBloomRebuild) * time.Second
+	if !s.cfg.Seckill.ProductBloomEnabled || interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.BuildProductFilter(); err != nil {
+					log.Printf("Failed to rebuild product bloom filter: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// productMayExist 查询布隆过滤器，过滤器未构建或Redis出错时放行
+// 位图键被淘汰或清空后缺少构建完成标记，此时放行并在后台从数据库重建
+func (s *SeckillService) productMayExist(productID uint) bool {
+	if !s.productFilterReady.Load() {
+		return true
+	}
+	exists, err := s.productFilter.Exists(strconv.FormatUint(uint64(productID), 10))
+	if errors.Is(err, cache.ErrBloomNotBuilt) {
+		if !s.productFilterBuilding.Load() {
+			go func() {
+				if err := s.BuildProductFilter(); err != nil {
+					log.Printf("Failed to rebuild product bloom filter: %v", err)
+				}
+			}()
+		}
+		return true
+	}
+	if err != nil {
+		log.Printf("Failed to check product bloom filter %d: %v", productID, err)
+		return true
+	}
+	return exists
+}
+
+// addToProductFilter 将新建或恢复的商品加入布隆过滤器
+func (s *SeckillService) addToProductFilter(productID uint) {
+	if !s.cfg.Seckill.ProductBloomEnabled {
+		return
+	}
+	if err := s.productFilter.Add(strconv.FormatUint(uint64(productID), 10)); err != nil {
+		log.Printf("Failed to add product %d to bloom filter: %v", productID, err)
+	}
+}
+
+// invalidateProductCache 使商品详情缓存失效，管理端修改商品后调用
+// 先递增版本号并删除Redis缓存，再删除本地缓存并通知其他实例删除，各实例都按先Redis后本地的顺序失效，
+// 本地缓存重新加载时不会再从Redis读到旧数据；读己之写窗口内回填缓存读主库
+func (s *SeckillService) invalidateProductCache(productID uint) {
+	s.markWritten("product", strconv.FormatUint(uint64(productID), 10))
+	key := s.productCacheKey(productID)
+	if err := productInvalidateScript.Run([]string{key, s.productVersionKey(productID)}).Err(); err != nil {
+		log.Printf("Failed to invalidate product cache %d: %v", productID, err)
+	}
+	if err := s.localProducts.Invalidate(key); err != nil {
+		log.Printf("Failed to broadcast product cache invalidation %d: %v", productID, err)
+	}
+}
+
+// encodeProductHash 将商品编码为哈希字段，字段名为JSON字段名，值为该字段的JSON编码
+func encodeProductHash(product *models.Product) (map[string]interface{}, error) {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{}, len(raw))
+	for field, value := range raw {
+		if !productCacheExcluded[field] {
+			values[field] = string(value)
+		}
+	}
+	return values, nil
+}
+
+func decodeProductHash(fields map[string]string) (*models.Product, error) {
+	parts := make([]string, 0, len(fields))
+	for field, value := range fields {
+		name, _ := json.Marshal(field)
+		parts = append(parts, string(name)+":"+value)
+	}
+
+	var product models.Product
+	if err := json.Unmarshal([]byte("{"+strings.Join(parts, ",")+"}"), &product); err != nil {
+		return nil, err
+	}
+	return &product, nil
+}
@@ -5,33 +5,82 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"regexp"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"go-seckill/audit"
 	"go-seckill/cache"
 	"go-seckill/config"
-	"go-seckill/database"
 	"go-seckill/models"
+	"go-seckill/repository"
 	"go-seckill/utils"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrInvalidProduct 商品参数校验失败
+var ErrInvalidProduct = errors.New("invalid product")
+
 type SeckillService struct {
-	cfg *config.Config
+	cfg          *config.Config
+	products     repository.ProductRepository
+	orders       repository.OrderRepository
+	reservations repository.ReservationRepository
+	users        repository.UserProfileRepository
+	// productLoads 合并同一商品并发的缓存未命中
+	productLoads singleflight.Group
+	// localProducts 进程内商品缓存，位于Redis缓存之前
+	localProducts *cache.LocalCache
+	// productFilter 商品ID布隆过滤器，构建完成前不拦截请求
+	productFilter      *cache.BloomFilter
+	productFilterReady atomic.Bool
+	// productFilterBuilding 正在重建布隆过滤器，避免并发重建
+	productFilterBuilding atomic.Bool
+}
+
+func NewSeckillService(cfg *config.Config, repos *repository.Repositories) *SeckillService {
+	return &SeckillService{
+		cfg:          cfg,
+		products:     repos.Products,
+		orders:       repos.Orders,
+		reservations: repos.Reservations,
+		users:        repos.Users,
+		localProducts: cache.NewLocalCache(cfg.Seckill.LocalCacheSize,
+			time.Duration(cfg.Seckill.LocalCacheTTL)*time.Second, cfg.Seckill.LocalCacheChannel),
+		productFilter: cache.NewBloomFilter(cfg.Seckill.ProductBloomKey,
+			cfg.Seckill.ProductBloomCapacity, cfg.Seckill.ProductBloomFPRate),
+	}
 }
 
-func NewSeckillService(cfg *config.Config) *SeckillService {
-	return &SeckillService{cfg: cfg}
+// StartCacheSync 订阅其他实例的商品缓存失效通知，ctx结束后停止
+func (s *SeckillService) StartCacheSync(ctx context.Context) {
+	s.localProducts.Start(ctx)
+}
+
+// CacheStats 返回进程内商品缓存的命中统计
+func (s *SeckillService) CacheStats() cache.LocalCacheStats {
+	return s.localProducts.Stats()
 }
 
 // PreheatStock 预热库存到Redis
-func (s *SeckillService) PreheatStock(productID uint, stock int) error {
-	key := fmt.Sprintf("%s%d", s.cfg.Seckill.StockPrefix, productID)
-	return cache.Set(key, stock, time.Duration(s.cfg.Seckill.TokenExpire)*time.Second)
+func (s *SeckillService) PreheatStock(product *models.Product, stock int) error {
+	_, err := s.moveStock(product, "set", int64(stock), LedgerReasonPreheat, fmt.Sprintf("product:%d", product.ID), "")
+	return err
 }
 
 // GetStockFromRedis 从Redis获取库存
 func (s *SeckillService) GetStockFromRedis(productID uint) (int64, error) {
-	key := fmt.Sprintf("%s%d", s.cfg.Seckill.StockPrefix, productID)
-	stockStr, err := cache.Get(key)
+	product, err := s.loadProduct(productID)
+	if err != nil {
+		return 0, err
+	}
+	return s.redisStock(product)
+}
+
+func (s *SeckillService) redisStock(product *models.Product) (int64, error) {
+	stockStr, err := cache.Get(s.stockKey(product))
 	if err != nil {
 		return 0, err
 	}
@@ -43,183 +92,504 @@ func (s *SeckillService) GetStockFromRedis(productID uint) (int64, error) {
 // GenerateToken 生成秒杀令牌
 func (s *SeckillService) GenerateToken(userID string, productID uint) (string, error) {
 	// 检查是否在秒杀时间
-	var product models.Product
-	if err := database.DB.First(&product, productID).Error; err != nil {
+	product, err := s.loadProduct(productID)
+	if err != nil {
 		return "", errors.New("product not found")
 	}
-
-	if !utils.IsSeckillTime(product.StartTime, product.EndTime) {
-		return "", errors.New("seckill not started or ended")
+	if err := s.checkPurchasable(userID, product); err != nil {
+		return "", err
 	}
 
 	// 检查库存
-	stock, err := s.GetStockFromRedis(productID)
+	stock, err := s.redisStock(product)
 	if err != nil || stock <= 0 {
 		return "", errors.New("out of stock")
 	}
 
-	// 生成令牌
+	// 生成令牌，令牌只能由领取它的用户购买对应的商品
 	token := fmt.Sprintf("%s-%d-%d", userID, productID, time.Now().UnixNano())
-	tokenKey := fmt.Sprintf("%s%s", s.cfg.Seckill.TokenPrefix, token)
-	
+
 	// 令牌有效期1小时
-	if err := cache.Set(tokenKey, "1", time.Duration(s.cfg.Seckill.TokenExpire)*time.Second); err != nil {
+	if err := cache.Set(s.tokenKey(inventoryTag(product), token), tokenOwner(userID, productID), time.Duration(s.cfg.Seckill.TokenExpire)*time.Second); err != nil {
 		return "", err
 	}
 
 	return token, nil
 }
 
-// Seckill 秒杀核心逻辑（使用Lua脚本保证原子性）
-func (s *SeckillService) Seckill(userID string, productID uint, token string) (*models.Order, error) {
-	// 验证令牌
-	tokenKey := fmt.Sprintf("%s%s", s.cfg.Seckill.TokenPrefix, token)
-	_, err := cache.Get(tokenKey)
-	if err != nil {
-		return nil, errors.New("invalid token")
-	}
-
-	// 使用Lua脚本保证原子性：检查库存 -> 扣减库存 -> 生成订单号
-	luaScript := `
-		local stockKey = KEYS[1]
-		local orderKey = KEYS[2]
-		local stock = tonumber(redis.call('get', stockKey) or 0)
-		
-		if stock <= 0 then
-			return {0, 'out of stock'}
-		end
-		
-		redis.call('decr', stockKey)
-		local orderNo = ARGV[1]
-		redis.call('setex', orderKey, 3600, orderNo)
-		
-		return {1, orderNo}
-	`
-
-	stockKey := fmt.Sprintf("%s%d", s.cfg.Seckill.StockPrefix, productID)
-	orderKey := fmt.Sprintf("%s%s:%d", s.cfg.Seckill.OrderPrefix, userID, productID)
-	orderNo := utils.GenerateOrderNo()
-
-	result, err := cache.Eval(luaScript, []string{stockKey, orderKey}, orderNo)
-	if err != nil {
-		return nil, fmt.Errorf("seckill failed: %w", err)
+// checkPurchasable 校验商品处于秒杀时间内，且用户满足预约和购买资格要求
+func (s *SeckillService) checkPurchasable(userID string, product *models.Product) error {
+	if !utils.IsSeckillTime(product.StartTime, product.EndTime) {
+		return errors.New("seckill not started or ended")
 	}
 
-	// 处理Lua脚本返回值
-	resultArray, ok := result.([]interface{})
-	if !ok || len(resultArray) < 2 {
-		return nil, errors.New("invalid lua script result")
+	// 预约制商品只允许已预约用户参与
+	if product.RequireReservation {
+		reserved, err := s.HasReservation(userID, product)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return errors.New("reservation required")
+		}
 	}
 
-	success, ok := resultArray[0].(int64)
-	if !ok {
-		return nil, errors.New("invalid lua script result type")
+	// 购买资格校验
+	return s.CheckEligibility(userID, product)
+}
+
+// tokenKey 令牌键使用商品的哈希标签，和库存键落在同一个槽，由扣减脚本原子地校验并删除
+func (s *SeckillService) tokenKey(tag, token string) string {
+	return fmt.Sprintf("%s{%s}:%s", s.cfg.Seckill.TokenPrefix, tag, token)
+}
+
+// tokenOwner 令牌的值，记录令牌签发给哪个用户购买哪个商品
+func tokenOwner(userID string, productID uint) string {
+	return fmt.Sprintf("%s:%d", userID, productID)
+}
+
+// tokenRejected 扣减脚本的FailedIndex，表示令牌不存在或不属于本次购买
+const tokenRejected = -1
+
+// seckillScript 秒杀扣减脚本，原子地校验令牌 -> 检查库存 -> 扣减库存 -> 写入下单标记 -> 记录库存流水 -> 删除令牌
+// KEYS: 库存键，用户下单标记键，库存流水键，令牌键
+// ARGV: 订单号，令牌的值，流水保留条数
+var seckillScript = cache.RegisterScript("seckill.decrement", `
+	if redis.call('get', KEYS[4]) ~= ARGV[2] then
+		return {0, -1}
+	end
+
+	local stock = tonumber(redis.call('get', KEYS[1]) or 0)
+	if stock <= 0 then
+		return {0, 1}
+	end
+
+	local balance = redis.call('decr', KEYS[1])
+	local orderNo = ARGV[1]
+	redis.call('setex', KEYS[2], 3600, orderNo)
+	redis.call('xadd', KEYS[3], 'MAXLEN', '~', ARGV[3], '*', 'reason', 'decrement', 'delta', -1, 'balance', balance, 'correlation_id', orderNo, 'note', '')
+	redis.call('del', KEYS[4])
+
+	-- 返回扣减成功时的Redis服务器时间，用于确定降价拍的成交价
+	local now = redis.call('time')
+	return {1, 0, now[1], now[2]}
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	if ok, err := checkToken(tx, keys[3], args[1]); !ok {
+		return []interface{}{0, tokenRejected}, err
 	}
 
-	if success == 0 {
-		errMsg, _ := resultArray[1].(string)
-		if errMsg == "" {
-			errMsg = "seckill failed"
-		}
-		return nil, errors.New(errMsg)
+	stock, err := tx.GetInt(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	if stock <= 0 {
+		return []interface{}{0, 1}, nil
+	}
+
+	maxLen, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := tx.IncrBy(keys[0], -1)
+	if err != nil {
+		return nil, err
 	}
+	orderNo := args[0]
+	if err := tx.Set(keys[1], orderNo, time.Hour); err != nil {
+		return nil, err
+	}
+	if _, err := tx.XAddMaxLen(keys[2], maxLen, map[string]interface{}{
+		"reason": "decrement", "delta": -1, "balance": balance, "correlation_id": orderNo, "note": "",
+	}); err != nil {
+		return nil, err
+	}
+	tx.Del(keys[3])
+	return decrementSuccess(tx), nil
+})
+
+// checkToken 对应脚本中的 redis.call('get', tokenKey) ~= owner 判断
+func checkToken(tx *cache.MemoryTx, key, owner string) (bool, error) {
+	v, err := tx.Get(key)
+	if errors.Is(err, cache.Nil) {
+		return false, nil
+	}
+	return err == nil && v == owner, err
+}
 
-	// 获取商品信息
-	var product models.Product
-	if err := database.DB.First(&product, productID).Error; err != nil {
+// decrementSuccess 扣减成功的返回值，与脚本一样以字符串返回TIME的秒和微秒
+func decrementSuccess(tx *cache.MemoryTx) []interface{} {
+	now := tx.Time()
+	return []interface{}{1, 0, strconv.FormatInt(now.Unix(), 10), strconv.FormatInt(int64(now.Nanosecond()/1000), 10)}
+}
+
+// orderLockWait 写入订单前等待订单锁的最长时间
+const orderLockWait = 300 * time.Millisecond
+
+// decrementReply 秒杀和组合购扣减脚本的返回值
+type decrementReply struct {
+	OK bool
+	// FailedIndex 库存不足的商品在请求中的序号，从1开始；令牌无效时为tokenRejected
+	FailedIndex int
+	// Sec、Usec 扣减成功时的Redis服务器时间（TIME）
+	Sec  int64
+	Usec int64
+}
+
+// at 返回扣减成功时刻，缺失时退化为本机时间
+func (r decrementReply) at() time.Time {
+	if r.Sec == 0 {
+		return time.Now()
+	}
+	return time.Unix(r.Sec, r.Usec*int64(time.Microsecond))
+}
+
+// Seckill 秒杀核心逻辑（使用Lua脚本保证原子性）
+// 令牌必须是签发给该用户购买该商品的，扣减成功时在同一脚本中删除
+func (s *SeckillService) Seckill(userID string, productID uint, token string) (*models.Order, error) {
+	// 库存相关的键带有商品的哈希标签，需要先取得商品信息
+	product, err := s.loadProduct(productID)
+	if err != nil {
 		return nil, errors.New("product not found")
 	}
+	orderNo := s.newOrderNo(userID)
+
+	var reply decrementReply
+	keys := []string{s.stockKey(product), s.orderKey(product, userID), s.ledgerKey(product), s.tokenKey(inventoryTag(product), token)}
+	if err := seckillScript.Run(keys, orderNo, tokenOwner(userID, productID), s.ledgerMaxLen()).Scan(&reply); err != nil {
+		return nil, fmt.Errorf("seckill failed: %w", err)
+	}
+	if !reply.OK {
+		if reply.FailedIndex == tokenRejected {
+			return nil, errors.New("invalid token")
+		}
+		return nil, errors.New("out of stock")
+	}
+	decrementedAt := reply.at()
 
-	// 创建订单
+	// 创建订单，记录扣减成功时适用的价格
+	price, _ := s.priceAt(product, decrementedAt)
 	order := &models.Order{
 		OrderNo:     orderNo,
 		UserID:      userID,
 		ProductID:   productID,
 		ProductName: product.Name,
-		Price:       product.Price,
+		Price:       price,
 		Status:      models.OrderStatusPending,
 	}
 
 	// 使用分布式锁保护数据库写入
 	lockKey := fmt.Sprintf("%sorder:%s", s.cfg.Seckill.LockPrefix, orderNo)
 	lock := utils.NewDistributedLock(lockKey, 5*time.Second)
-	
-	locked, err := lock.TryLockWithRetry(3, 100*time.Millisecond)
-	if !locked {
+
+	lockCtx, cancel := context.WithTimeout(context.Background(), orderLockWait)
+	defer cancel()
+	if err := lock.LockContext(lockCtx); err != nil {
+		s.rollbackSeckill(userID, orderNo, product)
 		return nil, errors.New("failed to acquire lock")
 	}
 	defer lock.Unlock()
+	// 数据库写入可能超过锁的有效期，由看门狗续期
+	lost := lock.StartWatchdog(context.Background())
 
-	if err := database.DB.Create(order).Error; err != nil {
+	if err := s.orders.Create(order); err != nil {
 		log.Printf("Failed to create order: %v", err)
-		// 回滚库存
-		cache.Incr(stockKey)
+		s.rollbackSeckill(userID, orderNo, product)
 		return nil, errors.New("failed to create order")
 	}
-
-	// 删除令牌
-	cache.Del(tokenKey)
+	s.markWritten("order", order.OrderNo)
+	s.invalidateEligibility(userID)
+	warnIfLockLost(lost, lockKey)
 
 	return order, nil
 }
 
+// rollbackSeckill 回滚秒杀扣减的库存和下单标记
+func (s *SeckillService) rollbackSeckill(userID, orderNo string, product *models.Product) {
+	s.moveStock(product, "incr", 1, LedgerReasonRollback, orderNo, "order creation failed")
+	cache.Del(s.orderKey(product, userID))
+}
+
+// warnIfLockLost 记录写入期间失去的订单锁，订单已经写入，只能记录日志供排查
+func warnIfLockLost(lost <-chan error, lockKey string) {
+	select {
+	case err, ok := <-lost:
+		if ok {
+			log.Printf("Lock %s lost while writing order: %v", lockKey, err)
+		}
+	default:
+	}
+}
+
+// newOrderNo 生成订单号，订单号中编码用户所在的订单分片
+func (s *SeckillService) newOrderNo(userID string) string {
+	shards := s.cfg.Database.OrderShards
+	return utils.GenerateOrderNo(utils.OrderShard(userID, shards), shards)
+}
+
+// QueryOrders 按条件查询订单（管理报表），分片时汇总所有分片的结果
+func (s *SeckillService) QueryOrders(query repository.OrderQuery) ([]models.Order, error) {
+	return s.orders.Query(query)
+}
+
 // CheckUserOrder 检查用户是否已经下过单
 func (s *SeckillService) CheckUserOrder(userID string, productID uint) (bool, error) {
-	orderKey := fmt.Sprintf("%s%s:%d", s.cfg.Seckill.OrderPrefix, userID, productID)
-	_, err := cache.Get(orderKey)
-	if err == nil {
-		return true, nil
+	// 商品不存在（如已删除）时没有下单标记可查，直接查数据库
+	if product, err := s.loadProduct(productID); err == nil {
+		if _, err := cache.Get(s.orderKey(product, userID)); err == nil {
+			return true, nil
+		}
 	}
 
 	// 检查数据库
-	var count int64
-	database.DB.Model(&models.Order{}).
-		Where("user_id = ? AND product_id = ? AND status != ?", userID, productID, models.OrderStatusCancelled).
-		Count(&count)
-	
+	count, err := s.orders.CountActiveByUserProduct(userID, productID)
+	if err != nil {
+		return false, err
+	}
 	return count > 0, nil
 }
 
 // GetProduct 获取商品信息
 func (s *SeckillService) GetProduct(productID uint) (*models.Product, error) {
-	var product models.Product
-	if err := database.DB.First(&product, productID).Error; err != nil {
-		return nil, err
-	}
-	return &product, nil
-}
-
-// ListProducts 获取商品列表
-func (s *SeckillService) ListProducts() ([]models.Product, error) {
-	var products []models.Product
-	if err := database.DB.Find(&products).Error; err != nil {
+	product, err := s.loadProduct(productID)
+	if err != nil {
 		return nil, err
 	}
-	return products, nil
+	s.applyCurrentPrice(product, time.Now())
+	return product, nil
 }
 
 // CreateProduct 创建商品
-func (s *SeckillService) CreateProduct(product *models.Product) error {
-	if err := database.DB.Create(product).Error; err != nil {
+func (s *SeckillService) CreateProduct(ctx context.Context, product *models.Product) error {
+	if err := validateProduct(product); err != nil {
 		return err
 	}
+	if err := s.products.Create(product); err != nil {
+		return err
+	}
+	s.addToProductFilter(product.ID)
+	// 清除创建前对该ID的负缓存
+	s.invalidateProductCache(product.ID)
+	audit.Record(ctx, "product.create", "product", product.ID, nil, product)
+	s.applyCurrentPrice(product, time.Now())
 	// 预热库存到Redis
-	return s.PreheatStock(product.ID, product.SeckillStock)
+	return s.PreheatStock(product, product.SeckillStock)
 }
 
 // GetOrder 获取订单信息
+// 订单刚写入时走主库，保证下单后立即查询能读到；其余情况走从库，从库未同步到时回退主库
 func (s *SeckillService) GetOrder(orderNo string) (*models.Order, error) {
-	var order models.Order
-	if err := database.DB.Where("order_no = ?", orderNo).First(&order).Error; err != nil {
-		return nil, err
+	if s.recentlyWritten("order", orderNo) {
+		return s.orders.FindByOrderNo(orderNo)
 	}
-	return &order, nil
+	order, err := s.orders.FindByOrderNo(orderNo, repository.FromReplica())
+	if errors.Is(err, repository.ErrNotFound) {
+		return s.orders.FindByOrderNo(orderNo)
+	}
+	return order, err
 }
 
 // UpdateOrderStatus 更新订单状态
-func (s *SeckillService) UpdateOrderStatus(orderNo string, status string) error {
-	return database.DB.Model(&models.Order{}).
-		Where("order_no = ?", orderNo).
-		Update("status", status).Error
+func (s *SeckillService) UpdateOrderStatus(ctx context.Context, orderNo string, status string) error {
+	before, err := s.orders.FindByOrderNo(orderNo)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.orders.UpdateStatus(orderNo, status); err != nil {
+		return err
+	}
+	s.markWritten("order", orderNo)
+	s.invalidateEligibility(before.UserID)
+
+	after := *before
+	after.Status = status
+	audit.Record(ctx, "order.update_status", "order", orderNo, before, &after)
+
+	// 取消订单时归还库存
+	if status == models.OrderStatusCancelled {
+		return s.returnCancelledStock(before)
+	}
+	return nil
+}
+
+// markWritten 标记订单或商品刚被写入，窗口期内的查询读主库
+func (s *SeckillService) markWritten(kind, id string) {
+	window := s.cfg.Database.ReadYourWritesWindow
+	if window <= 0 {
+		return
+	}
+	key := s.cfg.Seckill.RYWPrefix + kind + ":" + id
+	if err := cache.Set(key, 1, time.Duration(window)*time.Second); err != nil {
+		log.Printf("Failed to mark %s %s as recently written: %v", kind, id, err)
+	}
+}
+
+func (s *SeckillService) recentlyWritten(kind, id string) bool {
+	_, err := cache.Get(s.cfg.Seckill.RYWPrefix + kind + ":" + id)
+	return err == nil
+}
+
+// inventoryGroupPattern 库存分组会拼进Redis键的哈希标签，不能包含花括号等字符
+var inventoryGroupPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{0,64}$`)
+
+// validateProduct 校验商品参数
+func validateProduct(product *models.Product) error {
+	if !product.EndTime.After(product.StartTime) {
+		return fmt.Errorf("%w: end_time must be after start_time", ErrInvalidProduct)
+	}
+	if !inventoryGroupPattern.MatchString(product.InventoryGroup) {
+		return fmt.Errorf("%w: inventory_group may only contain letters, digits, '-' and '_' (at most 64)", ErrInvalidProduct)
+	}
+
+	switch product.PriceMode {
+	case "":
+		product.PriceMode = models.PriceModeFixed
+	case models.PriceModeFixed:
+	case models.PriceModeDutch:
+		if product.PriceStep <= 0 || product.StepInterval <= 0 {
+			return fmt.Errorf("%w: dutch mode requires positive price_step and step_interval", ErrInvalidProduct)
+		}
+		if product.FloorPrice < 0 || product.FloorPrice > product.Price {
+			return fmt.Errorf("%w: floor_price must be between 0 and price", ErrInvalidProduct)
+		}
+	default:
+		return fmt.Errorf("%w: unknown price_mode %q", ErrInvalidProduct, product.PriceMode)
+	}
+
+	if _, err := BuildEligibilityRules(product.EligibilityRules); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+	return nil
+}
+
+// UpdateProductRules 更新商品的购买资格规则（管理接口）
+func (s *SeckillService) UpdateProductRules(ctx context.Context, productID uint, rules []models.RuleConfig) (*models.Product, error) {
+	if _, err := BuildEligibilityRules(rules); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+	}
+
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *product
+	product.EligibilityRules = rules
+	if err := s.products.Update(product, "EligibilityRules"); err != nil {
+		return nil, err
+	}
+	s.invalidateProductCache(productID)
+	audit.Record(ctx, "product.update_rules", "product", productID, &before, product)
+	return product, nil
+}
+
+// priceAt 计算商品在指定时刻的价格及下一次降价时间
+func (s *SeckillService) priceAt(product *models.Product, at time.Time) (float64, *time.Time) {
+	if product.PriceMode != models.PriceModeDutch {
+		return product.Price, nil
+	}
+	return utils.DutchAuctionPrice(
+		product.StartTime,
+		product.EndTime,
+		product.Price,
+		product.FloorPrice,
+		product.PriceStep,
+		time.Duration(product.StepInterval)*time.Second,
+		at,
+	)
+}
+
+// applyCurrentPrice 填充商品的当前价格和下一次降价时间
+func (s *SeckillService) applyCurrentPrice(product *models.Product, now time.Time) {
+	product.CurrentPrice, product.NextPriceDropAt = s.priceAt(product, now)
+}
+
+// Reserve 预约秒杀，仅在开售前的预约窗口内有效
+func (s *SeckillService) Reserve(userID string, productID uint) error {
+	product, err := s.loadProduct(productID)
+	if err != nil {
+		return errors.New("product not found")
+	}
+
+	now := time.Now()
+	openAt := product.StartTime.Add(-time.Duration(s.cfg.Seckill.ReservationWindow) * time.Second)
+	if now.Before(openAt) {
+		return errors.New("reservation not open")
+	}
+	if !utils.BeforeSeckillTime(product.StartTime) {
+		return errors.New("reservation closed")
+	}
+
+	reserved, err := s.reservations.Exists(userID, productID)
+	if err != nil {
+		return err
+	}
+	if reserved {
+		return errors.New("already reserved")
+	}
+
+	reservation := &models.Reservation{UserID: userID, ProductID: productID}
+	if err := s.reservations.Create(reservation); err != nil {
+		log.Printf("Failed to create reservation: %v", err)
+		return errors.New("failed to create reservation")
+	}
+
+	return s.cacheReservation(userID, product)
+}
+
+// HasReservation 判断用户是否已预约，Redis未命中时回查数据库并回填
+func (s *SeckillService) HasReservation(userID string, product *models.Product) (bool, error) {
+	key := fmt.Sprintf("%s%d", s.cfg.Seckill.ReservePrefix, product.ID)
+	if ok, err := cache.SIsMember(key, userID); err == nil && ok {
+		return true, nil
+	}
+
+	reserved, err := s.reservations.Exists(userID, product.ID)
+	if err != nil || !reserved {
+		return false, err
+	}
+
+	if err := s.cacheReservation(userID, product); err != nil {
+		log.Printf("Failed to backfill reservation cache: %v", err)
+	}
+	return true, nil
 }
 
+// cacheReservation 将预约写入Redis集合，集合在活动结束后过期
+func (s *SeckillService) cacheReservation(userID string, product *models.Product) error {
+	key := fmt.Sprintf("%s%d", s.cfg.Seckill.ReservePrefix, product.ID)
+	if err := cache.SAdd(key, userID); err != nil {
+		return err
+	}
+	ttl := time.Until(product.EndTime) + time.Duration(s.cfg.Seckill.TokenExpire)*time.Second
+	return cache.Expire(key, ttl)
+}
+
+// ProductStats 商品统计信息
+type ProductStats struct {
+	ProductID    uint  `json:"product_id"`
+	Reservations int64 `json:"reservations"`
+	Orders       int64 `json:"orders"`
+	RedisStock   int64 `json:"redis_stock"`
+}
+
+// GetProductStats 获取商品统计信息（管理接口）
+func (s *SeckillService) GetProductStats(productID uint) (*ProductStats, error) {
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &ProductStats{ProductID: productID}
+	if stats.Reservations, err = s.reservations.CountByProduct(productID); err != nil {
+		return nil, err
+	}
+	if stats.Orders, err = s.orders.CountActiveByProduct(productID); err != nil {
+		return nil, err
+	}
+	stats.RedisStock, _ = s.redisStock(product)
+	return stats, nil
+}
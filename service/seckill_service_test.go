@@ -0,0 +1,1217 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-seckill/cache"
+	"go-seckill/config"
+	"go-seckill/database"
+	"go-seckill/models"
+	"go-seckill/repository"
+	"go-seckill/utils"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestService(t *testing.T) (*SeckillService, *repository.Repositories, cache.Store) {
+	t.Helper()
+	store := newTestStore(t)
+	cache.SetStore(store)
+
+	repos := newTestRepositories(t)
+	return NewSeckillService(config.Load(), repos), repos, store
+}
+
+// newTestStore 默认使用内存存储，TEST_REDIS=miniredis 时改用miniredis，以执行真实的Lua脚本
+func newTestStore(t *testing.T) cache.Store {
+	t.Helper()
+	if os.Getenv("TEST_REDIS") != "miniredis" {
+		return cache.NewMemoryStore()
+	}
+	return newMiniredisStore(t)
+}
+
+func newMiniredisStore(t *testing.T) cache.Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return cache.NewRedisStore(client)
+}
+
+// newTestRepositories 默认使用内存实现，TEST_DB_DRIVER=sqlite 时改用迁移后的SQLite数据库
+func newTestRepositories(t *testing.T) *repository.Repositories {
+	t.Helper()
+	if os.Getenv("TEST_DB_DRIVER") != database.DriverSQLite {
+		return repository.NewMemoryRepositories()
+	}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if _, err := database.MigrateUp(db); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	database.DB = db
+	t.Cleanup(func() { database.DB = nil })
+	return repository.NewGormRepositories(db, nil, 1)
+}
+
+// putUserProfile 写入用户资料，兼容内存和GORM实现
+func putUserProfile(t *testing.T, repos *repository.Repositories, profile models.UserProfile) {
+	t.Helper()
+	if memory, ok := repos.Users.(*repository.MemoryUserProfileRepository); ok {
+		memory.Put(profile)
+		return
+	}
+	if err := database.DB.Create(&profile).Error; err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+}
+
+func createLiveProduct(t *testing.T, s *SeckillService, stock int) *models.Product {
+	t.Helper()
+	return createGroupedProduct(t, s, "", stock)
+}
+
+// createGroupedProduct 创建指定库存分组的在售商品，同组商品才能组合购买
+func createGroupedProduct(t *testing.T, s *SeckillService, group string, stock int) *models.Product {
+	t.Helper()
+	product := &models.Product{
+		Name:           fmt.Sprintf("product-%d", stock),
+		Price:          99.99,
+		Stock:          stock,
+		SeckillStock:   stock,
+		InventoryGroup: group,
+		StartTime:      time.Now().Add(-time.Minute),
+		EndTime:        time.Now().Add(time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), product); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	return product
+}
+
+func buy(t *testing.T, s *SeckillService, userID string, productID uint) (*models.Order, error) {
+	t.Helper()
+	token, err := s.GenerateToken(userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Seckill(userID, productID, token)
+}
+
+func TestSeckillCreatesOrderAndDecrementsStock(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 2)
+
+	order, err := buy(t, s, "u1", product.ID)
+	if err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+	if order.Price != 99.99 || order.Status != models.OrderStatusPending {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	stock, err := s.GetStockFromRedis(product.ID)
+	if err != nil || stock != 1 {
+		t.Errorf("stock = %d, %v; want 1", stock, err)
+	}
+
+	hasOrder, err := s.CheckUserOrder("u1", product.ID)
+	if err != nil || !hasOrder {
+		t.Errorf("CheckUserOrder = %v, %v; want true", hasOrder, err)
+	}
+
+	found, err := s.GetOrder(order.OrderNo)
+	if err != nil || found.UserID != "u1" {
+		t.Errorf("GetOrder = %+v, %v", found, err)
+	}
+}
+
+type failingOrders struct {
+	repository.OrderRepository
+	fail atomic.Bool
+}
+
+func (r *failingOrders) Create(order *models.Order) error {
+	if r.fail.Load() {
+		return errors.New("database unavailable")
+	}
+	return r.OrderRepository.Create(order)
+}
+
+func TestSeckillRollsBackWhenOrderCreationFails(t *testing.T) {
+	_, repos, _ := newTestService(t)
+	orders := &failingOrders{OrderRepository: repos.Orders}
+	repos.Orders = orders
+	s := NewSeckillService(config.Load(), repos)
+	product := createLiveProduct(t, s, 1)
+
+	orders.fail.Store(true)
+	if _, err := buy(t, s, "u1", product.ID); err == nil || err.Error() != "failed to create order" {
+		t.Fatalf("Seckill err = %v; want failed to create order", err)
+	}
+	if stock, err := s.GetStockFromRedis(product.ID); err != nil || stock != 1 {
+		t.Errorf("stock after rollback = %d, %v; want 1", stock, err)
+	}
+
+	// 下单标记随库存一起回滚，不会显示为已购买
+	if hasOrder, err := s.CheckUserOrder("u1", product.ID); err != nil || hasOrder {
+		t.Errorf("CheckUserOrder after rollback = %v, %v; want false", hasOrder, err)
+	}
+	orders.fail.Store(false)
+	if _, err := buy(t, s, "u1", product.ID); err != nil {
+		t.Errorf("Seckill after rollback: %v", err)
+	}
+}
+
+func TestOrderNoEncodesUserShard(t *testing.T) {
+	s, _, _ := newTestService(t)
+	s.cfg.Database.OrderShards = 8
+	product := createLiveProduct(t, s, 10)
+
+	for _, userID := range []string{"u1", "u2", "u3", "u4"} {
+		order, err := buy(t, s, userID, product.ID)
+		if err != nil {
+			t.Fatalf("Seckill(%s): %v", userID, err)
+		}
+		shard, shards, ok := utils.ParseOrderShard(order.OrderNo)
+		if !ok || shard != utils.OrderShard(userID, 8) || shards != 8 {
+			t.Errorf("order %s of %s decodes to shard %d/%d, %v; want %d/8", order.OrderNo, userID, shard, shards, ok, utils.OrderShard(userID, 8))
+		}
+	}
+
+	// 旧格式订单号不含分片信息，不能被当作分片号解析
+	if _, _, ok := utils.ParseOrderShard("ORD1700000000abcdef12"); ok {
+		t.Error("legacy order number parsed as sharded")
+	}
+	// 无法识别的订单号返回错误，而不是静默忽略
+	if err := s.UpdateOrderStatus(context.Background(), "bogus", models.OrderStatusPaid); !errors.Is(err, repository.ErrUnroutableOrder) {
+		t.Errorf("UpdateOrderStatus(bogus) err = %v; want ErrUnroutableOrder", err)
+	}
+	if _, err := s.GetOrder("bogus"); !errors.Is(err, repository.ErrUnroutableOrder) {
+		t.Errorf("GetOrder(bogus) err = %v; want ErrUnroutableOrder", err)
+	}
+
+	orders, err := s.QueryOrders(repository.OrderQuery{ProductID: product.ID, Limit: 3})
+	if err != nil || len(orders) != 3 {
+		t.Errorf("QueryOrders = %d orders, %v; want 3", len(orders), err)
+	}
+}
+
+func TestSeckillOutOfStock(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 1)
+
+	if _, err := buy(t, s, "u1", product.ID); err != nil {
+		t.Fatalf("first Seckill: %v", err)
+	}
+
+	token, err := s.GenerateToken("u2", product.ID)
+	if err == nil {
+		_, err = s.Seckill("u2", product.ID, token)
+	}
+	if err == nil || err.Error() != "out of stock" {
+		t.Errorf("second buyer err = %v; want out of stock", err)
+	}
+}
+
+func TestSeckillRejectsInvalidToken(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 1)
+
+	if _, err := s.Seckill("u1", product.ID, "forged"); err == nil || err.Error() != "invalid token" {
+		t.Errorf("err = %v; want invalid token", err)
+	}
+}
+
+func TestSeckillRejectsTokenOfOtherProductOrUser(t *testing.T) {
+	s, _, _ := newTestService(t)
+	open := createLiveProduct(t, s, 1)
+	reserved := &models.Product{
+		Name:               "reserved",
+		Price:              10,
+		SeckillStock:       1,
+		RequireReservation: true,
+		StartTime:          time.Now().Add(-time.Minute),
+		EndTime:            time.Now().Add(time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), reserved); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	// 用不需要预约的商品A的令牌购买预约制商品B
+	token, err := s.GenerateToken("u1", open.ID)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := s.Seckill("u1", reserved.ID, token); err == nil || err.Error() != "invalid token" {
+		t.Errorf("buy B with token of A err = %v; want invalid token", err)
+	}
+	if _, err := s.Seckill("u2", open.ID, token); err == nil || err.Error() != "invalid token" {
+		t.Errorf("buy with token of another user err = %v; want invalid token", err)
+	}
+	if stock, _ := s.GetStockFromRedis(reserved.ID); stock != 1 {
+		t.Errorf("reserved stock = %d; want 1", stock)
+	}
+
+	// 令牌在扣减成功时被消费，不能重复使用
+	if _, err := s.Seckill("u1", open.ID, token); err != nil {
+		t.Fatalf("Seckill with own token: %v", err)
+	}
+	if _, err := s.Seckill("u1", open.ID, token); err == nil || err.Error() != "invalid token" {
+		t.Errorf("reused token err = %v; want invalid token", err)
+	}
+}
+
+func TestCreateProductValidation(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	product := &models.Product{
+		Name:      "dutch",
+		Price:     100,
+		PriceMode: models.PriceModeDutch,
+		StartTime: time.Now(),
+		EndTime:   time.Now().Add(time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), product); !errors.Is(err, ErrInvalidProduct) {
+		t.Errorf("err = %v; want ErrInvalidProduct", err)
+	}
+
+	product.PriceMode = models.PriceModeFixed
+	product.EligibilityRules = []models.RuleConfig{{Name: "unknown"}}
+	if err := s.CreateProduct(context.Background(), product); !errors.Is(err, ErrInvalidProduct) {
+		t.Errorf("err = %v; want ErrInvalidProduct", err)
+	}
+}
+
+func TestDutchAuctionPrice(t *testing.T) {
+	s, _, _ := newTestService(t)
+
+	start := time.Now().Add(-25 * time.Minute)
+	product := &models.Product{
+		Name:         "dutch",
+		Price:        999,
+		FloorPrice:   499,
+		PriceStep:    50,
+		StepInterval: 600,
+		PriceMode:    models.PriceModeDutch,
+		SeckillStock: 1,
+		StartTime:    start,
+		EndTime:      start.Add(2 * time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), product); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	detail, err := s.GetProduct(product.ID)
+	if err != nil {
+		t.Fatalf("GetProduct: %v", err)
+	}
+	if detail.CurrentPrice != 899 {
+		t.Errorf("current price = %v; want 899", detail.CurrentPrice)
+	}
+	if detail.NextPriceDropAt == nil || !detail.NextPriceDropAt.Equal(start.Add(30*time.Minute)) {
+		t.Errorf("next drop = %v; want %v", detail.NextPriceDropAt, start.Add(30*time.Minute))
+	}
+
+	order, err := buy(t, s, "u1", product.ID)
+	if err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+	if order.Price != 899 {
+		t.Errorf("order price = %v; want 899", order.Price)
+	}
+}
+
+func TestReservationGating(t *testing.T) {
+	s, _, store := newTestService(t)
+
+	product := &models.Product{
+		Name:               "reserved",
+		Price:              10,
+		SeckillStock:       5,
+		RequireReservation: true,
+		StartTime:          time.Now().Add(time.Hour),
+		EndTime:            time.Now().Add(2 * time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), product); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+
+	if err := s.Reserve("u1", product.ID); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := s.Reserve("u1", product.ID); err == nil || err.Error() != "already reserved" {
+		t.Errorf("duplicate Reserve err = %v; want already reserved", err)
+	}
+
+	// 开售后预约关闭，只有预约用户可以获取令牌
+	product.StartTime = time.Now().Add(-time.Minute)
+	if _, err := s.UpdateProduct(context.Background(), product.ID, product, false); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if err := s.Reserve("u2", product.ID); err == nil || err.Error() != "reservation closed" {
+		t.Errorf("late Reserve err = %v; want reservation closed", err)
+	}
+	if _, err := s.GenerateToken("u2", product.ID); err == nil || err.Error() != "reservation required" {
+		t.Errorf("GenerateToken err = %v; want reservation required", err)
+	}
+
+	// Redis集合丢失时回查数据库
+	store.Del(fmt.Sprintf("%s%d", s.cfg.Seckill.ReservePrefix, product.ID))
+	if _, err := s.GenerateToken("u1", product.ID); err != nil {
+		t.Errorf("GenerateToken for reserved user: %v", err)
+	}
+
+	stats, err := s.GetProductStats(product.ID)
+	if err != nil || stats.Reservations != 1 {
+		t.Errorf("stats = %+v, %v; want 1 reservation", stats, err)
+	}
+}
+
+func TestEligibilityRules(t *testing.T) {
+	s, repos, _ := newTestService(t)
+
+	first := createLiveProduct(t, s, 5)
+	if _, err := buy(t, s, "veteran", first.ID); err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+
+	product := createLiveProduct(t, s, 6)
+	if _, err := s.UpdateProductRules(context.Background(), product.ID, []models.RuleConfig{{Name: "new_user"}}); err != nil {
+		t.Fatalf("UpdateProductRules: %v", err)
+	}
+
+	var ineligible *IneligibleError
+	if _, err := s.GenerateToken("veteran", product.ID); !errors.As(err, &ineligible) || ineligible.Rule != "new_user" {
+		t.Errorf("veteran err = %v; want new_user rejection", err)
+	}
+	if _, err := s.GenerateToken("newcomer", product.ID); err != nil {
+		t.Errorf("newcomer: %v", err)
+	}
+
+	rules := []models.RuleConfig{{Name: "membership_tier", Params: []byte(`{"tiers":["gold"]}`)}}
+	if _, err := s.UpdateProductRules(context.Background(), product.ID, rules); err != nil {
+		t.Fatalf("UpdateProductRules: %v", err)
+	}
+	putUserProfile(t, repos, models.UserProfile{UserID: "goldie", Tier: "gold"})
+
+	if _, err := s.GenerateToken("goldie", product.ID); err != nil {
+		t.Errorf("gold member: %v", err)
+	}
+	if _, err := s.GenerateToken("newcomer", product.ID); !errors.As(err, &ineligible) || ineligible.Rule != "membership_tier" {
+		t.Errorf("non-member err = %v; want membership_tier rejection", err)
+	}
+}
+
+func TestEligibilityCacheInvalidatedAfterOrder(t *testing.T) {
+	s, _, _ := newTestService(t)
+	limited := createLiveProduct(t, s, 5)
+	rules := []models.RuleConfig{{Name: "no_recent_win", Params: []byte(`{"days":30}`)}}
+	if _, err := s.UpdateProductRules(context.Background(), limited.ID, rules); err != nil {
+		t.Fatalf("UpdateProductRules: %v", err)
+	}
+
+	// 通过校验的结果已缓存
+	if _, err := s.GenerateToken("u1", limited.ID); err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	// 缓存有效期内在其他商品中签，再次校验不能命中旧结果
+	other := createLiveProduct(t, s, 5)
+	if _, err := buy(t, s, "u1", other.ID); err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+	var ineligible *IneligibleError
+	if _, err := s.GenerateToken("u1", limited.ID); !errors.As(err, &ineligible) || ineligible.Rule != "no_recent_win" {
+		t.Errorf("GenerateToken after win err = %v; want no_recent_win rejection", err)
+	}
+}
+
+func TestSeckillBundleIsAllOrNothing(t *testing.T) {
+	s, _, store := newTestService(t)
+	console := createGroupedProduct(t, s, "console-set", 1)
+	controller := createGroupedProduct(t, s, "console-set", 2)
+
+	items := []BundleItem{{ProductID: console.ID}, {ProductID: controller.ID, Quantity: 2}}
+	token, err := s.GenerateBundleToken("u1", items)
+	if err != nil {
+		t.Fatalf("GenerateBundleToken: %v", err)
+	}
+	if err := s.PreheatStock(controller, 0); err != nil {
+		t.Fatalf("PreheatStock: %v", err)
+	}
+	if _, err := s.SeckillBundle("u1", items, token); err == nil {
+		t.Fatal("bundle succeeded with one item out of stock")
+	}
+	if stock, _ := s.GetStockFromRedis(console.ID); stock != 1 {
+		t.Errorf("console stock = %d; want 1 after failed bundle", stock)
+	}
+
+	if err := s.PreheatStock(controller, 2); err != nil {
+		t.Fatalf("PreheatStock: %v", err)
+	}
+	order, err := s.SeckillBundle("u1", items, token)
+	if err != nil {
+		t.Fatalf("SeckillBundle: %v", err)
+	}
+	if len(order.Items) != 2 || order.Price != 299.97 {
+		t.Errorf("unexpected bundle order %+v", order)
+	}
+	for _, id := range []uint{console.ID, controller.ID} {
+		if stock, _ := s.GetStockFromRedis(id); stock != 0 {
+			t.Errorf("product %d stock = %d; want 0", id, stock)
+		}
+	}
+
+	// 下单标记过期后，组合中的每个商品都能通过订单明细查到
+	for _, product := range []*models.Product{console, controller} {
+		store.Del(s.orderKey(product, "u1"))
+		if hasOrder, err := s.CheckUserOrder("u1", product.ID); err != nil || !hasOrder {
+			t.Errorf("CheckUserOrder(%d) = %v, %v; want true", product.ID, hasOrder, err)
+		}
+	}
+
+	// 按商品统计和查询订单时同样包含组合中的其余商品
+	if count, err := s.orders.CountActiveByProduct(controller.ID); err != nil || count != 1 {
+		t.Errorf("CountActiveByProduct(controller) = %d, %v; want 1", count, err)
+	}
+	if orders, err := s.orders.Query(repository.OrderQuery{ProductID: controller.ID}); err != nil || len(orders) != 1 {
+		t.Errorf("Query(controller) = %d orders, %v; want 1", len(orders), err)
+	}
+}
+
+func TestSeckillBundleRequiresBundleToken(t *testing.T) {
+	s, _, _ := newTestService(t)
+	open := createGroupedProduct(t, s, "set", 5)
+	reserved := &models.Product{
+		Name:               "reserved",
+		Price:              10,
+		SeckillStock:       5,
+		RequireReservation: true,
+		InventoryGroup:     "set",
+		StartTime:          time.Now().Add(-time.Minute),
+		EndTime:            time.Now().Add(time.Hour),
+	}
+	if err := s.CreateProduct(context.Background(), reserved); err != nil {
+		t.Fatalf("CreateProduct: %v", err)
+	}
+	items := []BundleItem{{ProductID: open.ID}, {ProductID: reserved.ID}}
+
+	// 组合中的每个商品都要校验预约
+	if _, err := s.GenerateBundleToken("u1", items); err == nil || !strings.Contains(err.Error(), "reservation required") {
+		t.Errorf("GenerateBundleToken err = %v; want reservation required", err)
+	}
+
+	// 单个商品的令牌和其他组合的令牌都不能用于组合购
+	single, err := s.GenerateToken("u1", open.ID)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if _, err := s.SeckillBundle("u1", items, single); err == nil || err.Error() != "invalid token" {
+		t.Errorf("bundle with single-product token err = %v; want invalid token", err)
+	}
+	other := createGroupedProduct(t, s, "set", 6)
+	bundle, err := s.GenerateBundleToken("u1", []BundleItem{{ProductID: open.ID}, {ProductID: other.ID}})
+	if err != nil {
+		t.Fatalf("GenerateBundleToken: %v", err)
+	}
+	if _, err := s.SeckillBundle("u1", items, bundle); err == nil || err.Error() != "invalid token" {
+		t.Errorf("bundle with token of another bundle err = %v; want invalid token", err)
+	}
+	if stock, _ := s.GetStockFromRedis(reserved.ID); stock != 5 {
+		t.Errorf("reserved stock = %d; want 5", stock)
+	}
+
+	// 令牌绑定每个商品的数量，领取1件的令牌不能买走更多库存
+	more := []BundleItem{{ProductID: open.ID, Quantity: 3}, {ProductID: other.ID}}
+	if _, err := s.SeckillBundle("u1", more, bundle); err == nil || err.Error() != "invalid token" {
+		t.Errorf("bundle with larger quantity err = %v; want invalid token", err)
+	}
+	if stock, _ := s.GetStockFromRedis(open.ID); stock != 5 {
+		t.Errorf("open stock = %d; want 5", stock)
+	}
+	tooMany := []BundleItem{{ProductID: open.ID, Quantity: s.cfg.Seckill.BundleMaxQuantity + 1}, {ProductID: other.ID}}
+	if _, err := s.GenerateBundleToken("u1", tooMany); err == nil || !strings.Contains(err.Error(), "invalid quantity") {
+		t.Errorf("GenerateBundleToken above the quantity limit err = %v; want invalid quantity", err)
+	}
+
+	// 令牌与商品顺序无关
+	if _, err := s.SeckillBundle("u1", []BundleItem{{ProductID: other.ID}, {ProductID: open.ID}}, bundle); err != nil {
+		t.Errorf("SeckillBundle in another order: %v", err)
+	}
+}
+
+func TestInventoryKeysAreTaggedPerGroup(t *testing.T) {
+	s, _, _ := newTestService(t)
+	a := createLiveProduct(t, s, 1)
+	b := createLiveProduct(t, s, 1)
+	console := createGroupedProduct(t, s, "console-set", 1)
+	controller := createGroupedProduct(t, s, "console-set", 1)
+
+	// 未分组的商品各自一个标签，同组商品的库存、下单标记、流水和令牌都在同一个槽
+	if inventoryTag(a) == inventoryTag(b) {
+		t.Errorf("ungrouped products share tag %q", inventoryTag(a))
+	}
+	slot := cache.Slot(s.stockKey(console))
+	for _, key := range []string{
+		s.stockKey(controller), s.ledgerKey(controller), s.orderKey(controller, "u1"),
+		s.tokenKey(inventoryTag(controller), "t1"),
+	} {
+		if cache.Slot(key) != slot {
+			t.Errorf("key %s is not in slot %d", key, slot)
+		}
+	}
+
+	// 不同分组的商品不能组合购买
+	items := []BundleItem{{ProductID: a.ID}, {ProductID: console.ID}}
+	if _, err := s.GenerateBundleToken("u1", items); err == nil || !strings.Contains(err.Error(), "same inventory group") {
+		t.Errorf("GenerateBundleToken err = %v; want inventory group rejection", err)
+	}
+	if _, err := s.SeckillBundle("u1", items, "any"); err == nil || !strings.Contains(err.Error(), "same inventory group") {
+		t.Errorf("SeckillBundle err = %v; want inventory group rejection", err)
+	}
+
+	// 分组只能在创建时指定
+	update := *console
+	update.InventoryGroup = "other"
+	if _, err := s.UpdateProduct(context.Background(), console.ID, &update, true); !errors.Is(err, ErrInvalidProduct) {
+		t.Errorf("UpdateProduct changing group err = %v; want ErrInvalidProduct", err)
+	}
+	invalid := &models.Product{Name: "x", InventoryGroup: "a}b", StartTime: time.Now(), EndTime: time.Now().Add(time.Hour)}
+	if err := s.CreateProduct(context.Background(), invalid); !errors.Is(err, ErrInvalidProduct) {
+		t.Errorf("CreateProduct with braces in group err = %v; want ErrInvalidProduct", err)
+	}
+}
+
+func TestMigrateInventoryKeysMovesLegacyKeys(t *testing.T) {
+	s, repos, store := newTestService(t)
+	live := createLiveProduct(t, s, 5)
+	deleted := createLiveProduct(t, s, 3)
+	if err := repos.Products.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// 模拟旧版本的键：库存和流水使用统一的 {inventory} 标签
+	for _, product := range []*models.Product{live, deleted} {
+		store.Del(s.stockKey(product))
+		store.Del(s.ledgerKey(product))
+		cache.Set(fmt.Sprintf("seckill:{inventory}:stock:%d", product.ID), 2, 0)
+		cache.XAdd(fmt.Sprintf("seckill:{inventory}:ledger:%d", product.ID), map[string]interface{}{"reason": LedgerReasonPreheat})
+	}
+	// 新键已存在的旧键不覆盖
+	cache.Set(fmt.Sprintf("seckill:stock:%d", live.ID), 9, 0)
+
+	result, err := s.MigrateInventoryKeys()
+	if err != nil {
+		t.Fatalf("MigrateInventoryKeys: %v", err)
+	}
+	if len(result.Moved) != 4 || len(result.Conflicts) != 1 {
+		t.Errorf("result = %+v; want 4 moved and 1 conflict", result)
+	}
+	if stock, _ := s.GetStockFromRedis(live.ID); stock != 2 {
+		t.Errorf("migrated stock = %d; want 2", stock)
+	}
+	for _, product := range []*models.Product{live, deleted} {
+		if messages, _ := cache.XRevRangeN(s.ledgerKey(product), "+", "-", 10); len(messages) != 1 {
+			t.Errorf("product %d ledger has %d entries; want 1", product.ID, len(messages))
+		}
+		if _, err := cache.Get(fmt.Sprintf("seckill:{inventory}:stock:%d", product.ID)); !errors.Is(err, cache.Nil) {
+			t.Errorf("legacy stock key of product %d still exists: %v", product.ID, err)
+		}
+	}
+
+	// 重复执行时没有需要迁移的键
+	if result, err := s.MigrateInventoryKeys(); err != nil || len(result.Moved) != 0 {
+		t.Errorf("second run = %+v, %v; want nothing moved", result, err)
+	}
+}
+
+func TestStockLedgerRecordsEveryMovement(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 3)
+
+	order, err := buy(t, s, "u1", product.ID)
+	if err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+	if err := s.UpdateOrderStatus(context.Background(), order.OrderNo, models.OrderStatusCancelled); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+	// 重复取消不会重复归还库存
+	if err := s.UpdateOrderStatus(context.Background(), order.OrderNo, models.OrderStatusCancelled); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+	if _, err := s.AdjustStock(context.Background(), product.ID, -5, "damaged", "req-1"); !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("AdjustStock below zero = %v; want ErrInsufficientStock", err)
+	}
+	if balance, err := s.AdjustStock(context.Background(), product.ID, -2, "damaged", "req-2"); err != nil || balance != 1 {
+		t.Fatalf("AdjustStock = %d, %v; want 1", balance, err)
+	}
+
+	entries, next, err := s.ListStockLedger(product.ID, "", 10)
+	if err != nil {
+		t.Fatalf("ListStockLedger: %v", err)
+	}
+	want := []struct {
+		reason      string
+		delta       int64
+		balance     int64
+		correlation string
+	}{
+		{LedgerReasonAdjust, -2, 1, "req-2"},
+		{LedgerReasonCancelReturn, 1, 3, order.OrderNo},
+		{LedgerReasonDecrement, -1, 2, order.OrderNo},
+		{LedgerReasonPreheat, 3, 3, fmt.Sprintf("product:%d", product.ID)},
+	}
+	if len(entries) != len(want) || next != "" {
+		t.Fatalf("ledger = %+v, next %q; want %d entries", entries, next, len(want))
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Reason != w.reason || e.Delta != w.delta || e.Balance != w.balance || e.CorrelationID != w.correlation {
+			t.Errorf("entry %d = %+v; want %+v", i, e, w)
+		}
+	}
+
+	// 分页读取与一次读取结果一致
+	page1, cursor, _ := s.ListStockLedger(product.ID, "", 3)
+	page2, last, _ := s.ListStockLedger(product.ID, cursor, 3)
+	if len(page1) != 3 || len(page2) != 1 || last != "" || page2[0].ID != entries[3].ID {
+		t.Errorf("paged ledger = %+v then %+v (next %q)", page1, page2, last)
+	}
+}
+
+func TestCancelReturnRetriesAfterFailedMove(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 3)
+	order, err := buy(t, s, "u1", product.ID)
+	if err != nil {
+		t.Fatalf("Seckill: %v", err)
+	}
+
+	// 库存键类型错误时归还失败，不能留下幂等标记，也不能清除下单标记
+	cache.Del(s.stockKey(product))
+	cache.HSet(s.stockKey(product), "broken", 1)
+	if err := s.UpdateOrderStatus(context.Background(), order.OrderNo, models.OrderStatusCancelled); err == nil {
+		t.Fatal("UpdateOrderStatus succeeded with a broken stock key")
+	}
+	if _, err := cache.Get(s.orderKey(product, "u1")); err != nil {
+		t.Errorf("order marker removed by a failed return: %v", err)
+	}
+	if entries, _, _ := s.ListStockLedger(product.ID, "", 10); len(entries) != 2 {
+		t.Errorf("ledger has %d entries after a failed return; want 2", len(entries))
+	}
+
+	// 修复后重试，库存归还且只归还一次
+	cache.Del(s.stockKey(product))
+	cache.Set(s.stockKey(product), 2, 0)
+	for i := 0; i < 2; i++ {
+		if err := s.UpdateOrderStatus(context.Background(), order.OrderNo, models.OrderStatusCancelled); err != nil {
+			t.Fatalf("UpdateOrderStatus retry: %v", err)
+		}
+	}
+	if stock, _ := s.GetStockFromRedis(product.ID); stock != 3 {
+		t.Errorf("stock = %d; want 3", stock)
+	}
+	if _, err := cache.Get(s.orderKey(product, "u1")); !errors.Is(err, cache.Nil) {
+		t.Errorf("order marker = %v; want removed", err)
+	}
+}
+
+// TestInventoryScriptsMatchLua 内存存储中脚本的Go实现与Lua脚本产生相同的返回值和库存流水
+func TestInventoryScriptsMatchLua(t *testing.T) {
+	// 流水保留条数较小，覆盖写入时裁剪最早流水的情况
+	const maxLen = 4
+	run := func(store cache.Store) []string {
+		cache.SetStore(store)
+		var trace []string
+		record := func(result *cache.ScriptResult) {
+			var reply struct {
+				OK    bool
+				Value int64
+			}
+			err := result.Scan(&reply)
+			trace = append(trace, fmt.Sprintf("%v %d %v", reply.OK, reply.Value, err))
+		}
+
+		keys := func(id int) []string {
+			return []string{fmt.Sprintf("seckill:stock:{g:set}:%d", id), fmt.Sprintf("seckill:order:{g:set}:u1:%d", id),
+				fmt.Sprintf("seckill:ledger:{g:set}:%d", id), "seckill:token:{g:set}:t1"}
+		}
+		one, two := keys(1), keys(2)
+		record(stockMoveScript.Run([]string{one[0], one[2]}, "set", 1, LedgerReasonPreheat, "p1", "", 60, maxLen))
+		record(stockMoveScript.Run([]string{two[0], two[2]}, "set", 3, LedgerReasonPreheat, "p2", "", 60, maxLen))
+		store.Set(one[3], "u1:1", time.Minute)
+		record(seckillScript.Run(one, "ORD0", "u1:2", maxLen))
+		record(seckillScript.Run(one, "ORD1", "u1:1", maxLen))
+		record(seckillScript.Run(one, "ORD2", "u1:1", maxLen))
+		store.Set(one[3], "u1:1", time.Minute)
+		record(seckillScript.Run(one, "ORD2", "u1:1", maxLen))
+		record(stockMoveScript.Run([]string{one[0], one[2]}, "incr", -1, LedgerReasonAdjust, "req", "", 0, maxLen))
+		record(stockMoveScript.Run([]string{one[0], one[2]}, "incr", 2, LedgerReasonAdjust, "req", "restock", 0, maxLen))
+		bundle := []string{one[0], two[0], one[1], two[1], one[2], two[2], one[3]}
+		record(bundleScript.Run(bundle, 2, "ORD3", 3, 1, "u1:1", maxLen))
+		record(bundleScript.Run(bundle, 2, "ORD4", 1, 2, "u1:1", maxLen))
+		record(bundleScript.Run(bundle, 2, "ORD5", 1, 2, "u1:1", maxLen))
+		refund := []string{"seckill:lock:{g:set}:stock-return:ORD3", one[0], two[0], one[2], two[2], one[1], two[1]}
+		record(stockReturnScript.Run(refund, 2, "ORD3", 60, 3, 1, maxLen))
+		record(stockReturnScript.Run(refund, 2, "ORD3", 60, 3, 1, maxLen))
+
+		_, err := store.Get(one[3])
+		trace = append(trace, fmt.Sprintf("token consumed=%v", errors.Is(err, cache.Nil)))
+		for _, key := range [][]string{one, two} {
+			stock, _ := store.Get(key[0])
+			marker, _ := store.Get(key[1])
+			ttl, _ := store.TTL(key[1])
+			trace = append(trace, fmt.Sprintf("stock=%s marker=%s ttl=%v", stock, marker, ttl))
+			messages, err := store.XRevRangeN(key[2], "+", "-", 0)
+			if err != nil {
+				t.Fatalf("XRevRangeN: %v", err)
+			}
+			if len(messages) > maxLen {
+				t.Errorf("ledger %s has %d entries; want at most %d", key[2], len(messages), maxLen)
+			}
+			for _, msg := range messages {
+				e := parseLedgerEntry(msg.ID, msg.Values)
+				trace = append(trace, fmt.Sprintf("%s %d %d %s %q", e.Reason, e.Delta, e.Balance, e.CorrelationID, e.Note))
+			}
+		}
+		return trace
+	}
+
+	lua := run(newMiniredisStore(t))
+	memory := run(cache.NewMemoryStore())
+	if len(lua) != len(memory) {
+		t.Fatalf("lua trace:\n%s\nmemory trace:\n%s", strings.Join(lua, "\n"), strings.Join(memory, "\n"))
+	}
+	for i := range lua {
+		if lua[i] != memory[i] {
+			t.Errorf("step %d: lua %q, memory %q", i, lua[i], memory[i])
+		}
+	}
+}
+
+func TestListProductsPaginationAndFilters(t *testing.T) {
+	s, repos, _ := newTestService(t)
+	now := time.Now()
+	for i, window := range [][2]time.Duration{
+		{-2 * time.Hour, -time.Hour}, // ended
+		{-time.Minute, time.Hour},    // live
+		{-time.Minute, time.Hour},    // live
+		{time.Hour, 2 * time.Hour},   // upcoming
+	} {
+		repos.Products.Create(&models.Product{
+			Name:      fmt.Sprintf("phone-%d", i),
+			Price:     10,
+			StartTime: now.Add(window[0]),
+			EndTime:   now.Add(window[1]),
+		})
+	}
+
+	page1, cursor, err := s.ListProducts(ProductListQuery{Limit: 3})
+	if err != nil || len(page1) != 3 || cursor == "" {
+		t.Fatalf("page1 = %d products, cursor %q, %v", len(page1), cursor, err)
+	}
+	page2, last, err := s.ListProducts(ProductListQuery{Cursor: cursor, Limit: 3})
+	if err != nil || len(page2) != 1 || last != "" || page2[0].ID != 4 {
+		t.Fatalf("page2 = %+v, cursor %q, %v", page2, last, err)
+	}
+	// 不分页时返回全部商品，供旧客户端使用
+	if all, next, err := s.ListProducts(ProductListQuery{}); err != nil || len(all) != 4 || next != "" {
+		t.Errorf("unpaged list = %d products, cursor %q, %v; want 4 and no cursor", len(all), next, err)
+	}
+
+	live, _, _ := s.ListProducts(ProductListQuery{Status: models.ProductStatusLive, Limit: 10})
+	if len(live) != 2 {
+		t.Errorf("live products = %d; want 2", len(live))
+	}
+	named, _, _ := s.ListProducts(ProductListQuery{Name: "phone-3", Limit: 10})
+	if len(named) != 1 || named[0].ID != 4 {
+		t.Errorf("name search = %+v", named)
+	}
+	// 通配符按字面匹配
+	if wildcard, _, _ := s.ListProducts(ProductListQuery{Name: "phone_", Limit: 10}); len(wildcard) != 0 {
+		t.Errorf("wildcard search = %+v; want no matches", wildcard)
+	}
+	if _, _, err := s.ListProducts(ProductListQuery{Status: "sold", Limit: 10}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("unknown status err = %v; want ErrInvalidQuery", err)
+	}
+	if _, _, err := s.ListProducts(ProductListQuery{Cursor: "!!", Limit: 10}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("bad cursor err = %v; want ErrInvalidQuery", err)
+	}
+}
+
+func TestListUserOrdersPagination(t *testing.T) {
+	s, repos, _ := newTestService(t)
+	for i := 0; i < 5; i++ {
+		repos.Orders.Create(&models.Order{OrderNo: fmt.Sprintf("ORD%d", i), UserID: "u1", ProductID: 1, Status: models.OrderStatusPending})
+	}
+	repos.Orders.Create(&models.Order{OrderNo: "ORD-other", UserID: "u2", ProductID: 1, Status: models.OrderStatusPending})
+	repos.Orders.UpdateStatus("ORD4", models.OrderStatusPaid)
+
+	var seen []string
+	cursor := ""
+	for {
+		orders, next, err := s.ListUserOrders("u1", "", cursor, 2)
+		if err != nil {
+			t.Fatalf("ListUserOrders: %v", err)
+		}
+		for _, order := range orders {
+			seen = append(seen, order.OrderNo)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if fmt.Sprint(seen) != "[ORD4 ORD3 ORD2 ORD1 ORD0]" {
+		t.Errorf("orders = %v; want newest first without gaps or duplicates", seen)
+	}
+
+	paid, _, _ := s.ListUserOrders("u1", models.OrderStatusPaid, "", 10)
+	if len(paid) != 1 || paid[0].OrderNo != "ORD4" {
+		t.Errorf("paid orders = %+v", paid)
+	}
+}
+
+func TestUpdateProductRequiresForceOnceLive(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 10)
+	buy(t, s, "u1", product.ID)
+
+	update := *product
+	update.Name = "renamed"
+	updated, err := s.UpdateProduct(context.Background(), product.ID, &update, false)
+	if err != nil || updated.Name != "renamed" {
+		t.Fatalf("rename live product = %+v, %v", updated, err)
+	}
+
+	update.SeckillStock = 20
+	if _, err := s.UpdateProduct(context.Background(), product.ID, &update, false); !errors.Is(err, ErrProductStarted) {
+		t.Fatalf("stock change without force err = %v; want ErrProductStarted", err)
+	}
+	if _, err := s.UpdateProduct(context.Background(), product.ID, &update, true); err != nil {
+		t.Fatalf("forced stock change: %v", err)
+	}
+	// 已售出1件，库存按差值调整
+	if stock, _ := s.GetStockFromRedis(product.ID); stock != 19 {
+		t.Errorf("redis stock = %d; want 19", stock)
+	}
+
+	update.EndTime = update.StartTime
+	if _, err := s.UpdateProduct(context.Background(), product.ID, &update, true); !errors.Is(err, ErrInvalidProduct) {
+		t.Errorf("invalid window err = %v; want ErrInvalidProduct", err)
+	}
+}
+
+func TestDeleteAndRestoreProduct(t *testing.T) {
+	s, _, _ := newTestService(t)
+	product := createLiveProduct(t, s, 5)
+	buy(t, s, "u1", product.ID)
+
+	if err := s.DeleteProduct(context.Background(), product.ID, false); !errors.Is(err, ErrProductStarted) {
+		t.Fatalf("delete live product err = %v; want ErrProductStarted", err)
+	}
+	if err := s.DeleteProduct(context.Background(), product.ID, true); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	if _, err := s.GetProduct(product.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("deleted product err = %v; want ErrNotFound", err)
+	}
+	if stock, _ := s.GetStockFromRedis(product.ID); stock != 0 {
+		t.Errorf("redis stock after delete = %d; want 0", stock)
+	}
+
+	restored, err := s.RestoreProduct(context.Background(), product.ID)
+	if err != nil || restored.ID != product.ID {
+		t.Fatalf("RestoreProduct = %+v, %v", restored, err)
+	}
+	if stock, _ := s.GetStockFromRedis(product.ID); stock != 4 {
+		t.Errorf("redis stock after restore = %d; want 4", stock)
+	}
+	if _, err := s.RestoreProduct(context.Background(), product.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("restore active product err = %v; want ErrNotFound", err)
+	}
+}
+
+// countingProducts 统计FindByID调用次数，并放慢查询以便并发请求在同一次查询上等待
+type countingProducts struct {
+	repository.ProductRepository
+	calls atomic.Int32
+}
+
+func (r *countingProducts) FindByID(id uint, opts ...repository.ReadOption) (*models.Product, error) {
+	r.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return r.ProductRepository.FindByID(id, opts...)
+}
+
+func TestProductCacheCoalescesMissesAndInvalidates(t *testing.T) {
+	_, repos, store := newTestService(t)
+	products := &countingProducts{ProductRepository: repos.Products}
+	repos.Products = products
+	s := NewSeckillService(config.Load(), repos)
+	product := createLiveProduct(t, s, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.GetProduct(product.ID); err != nil {
+				t.Errorf("GetProduct: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := products.calls.Load(); calls != 1 {
+		t.Errorf("concurrent misses hit the database %d times; want 1", calls)
+	}
+
+	key := s.productCacheKey(product.ID)
+	if ttl, _ := store.TTL(key); ttl < 300*time.Second || ttl > 330*time.Second {
+		t.Errorf("cache ttl = %v; want 300s plus up to 10%% jitter", ttl)
+	}
+	cached, err := s.GetProduct(product.ID)
+	if err != nil || cached.Name != product.Name || !cached.EndTime.Equal(product.EndTime) || products.calls.Load() != 1 {
+		t.Fatalf("cached product = %+v, %v after %d queries", cached, err, products.calls.Load())
+	}
+
+	update := *product
+	update.Name = "renamed"
+	if _, err := s.UpdateProduct(context.Background(), product.ID, &update, false); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if fresh, _ := s.GetProduct(product.ID); fresh.Name != "renamed" {
+		t.Errorf("product after update = %q; want renamed", fresh.Name)
+	}
+
+	// 不存在的商品写入负缓存
+	products.calls.Store(0)
+	for i := 0; i < 3; i++ {
+		if _, err := s.GetProduct(999); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("missing product err = %v; want ErrNotFound", err)
+		}
+	}
+	if calls := products.calls.Load(); calls != 1 {
+		t.Errorf("missing product hit the database %d times; want 1", calls)
+	}
+	if ttl, _ := store.TTL(s.productCacheKey(999)); ttl != 30*time.Second {
+		t.Errorf("negative cache ttl = %v; want 30s", ttl)
+	}
+}
+
+// laggingProducts 模拟复制延迟的从库，从库读到的是商品被修改前的快照
+type laggingProducts struct {
+	repository.ProductRepository
+	stale        map[uint]models.Product
+	replicaReads atomic.Int32
+}
+
+func (r *laggingProducts) FindByID(id uint, opts ...repository.ReadOption) (*models.Product, error) {
+	if !repository.ReadsFromReplica(opts...) {
+		return r.ProductRepository.FindByID(id, opts...)
+	}
+	r.replicaReads.Add(1)
+	if product, ok := r.stale[id]; ok {
+		return &product, nil
+	}
+	return r.ProductRepository.FindByID(id, opts...)
+}
+
+func TestProductCacheFillSkipsStaleRows(t *testing.T) {
+	_, repos, store := newTestService(t)
+	products := &laggingProducts{ProductRepository: repos.Products, stale: make(map[uint]models.Product)}
+	repos.Products = products
+	s := NewSeckillService(config.Load(), repos)
+	product := createLiveProduct(t, s, 10)
+	products.stale[product.ID] = *product
+
+	// 修改后的读己之写窗口内回填读主库，不会把从库中的旧数据写回缓存
+	update := *product
+	update.Name = "renamed"
+	if _, err := s.UpdateProduct(context.Background(), product.ID, &update, false); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if fresh, _ := s.GetProduct(product.ID); fresh.Name != "renamed" || products.replicaReads.Load() != 0 {
+		t.Errorf("product after update = %q with %d replica reads; want renamed from the primary", fresh.Name, products.replicaReads.Load())
+	}
+
+	// 窗口过后缓存未命中时读从库
+	delete(products.stale, product.ID)
+	store.Del(s.cfg.Seckill.RYWPrefix + fmt.Sprintf("product:%d", product.ID))
+	store.Del(s.productCacheKey(product.ID))
+	s.localProducts.Delete(s.productCacheKey(product.ID))
+	if _, err := s.GetProduct(product.ID); err != nil || products.replicaReads.Load() != 1 {
+		t.Errorf("GetProduct = %v with %d replica reads; want 1", err, products.replicaReads.Load())
+	}
+
+	// 失效前开始的回填带着旧版本号，不能写回缓存
+	version, _ := store.Get(s.productVersionKey(product.ID))
+	if version == "" {
+		version = "0"
+	}
+	s.invalidateProductCache(product.ID)
+	s.fillProductCache(product.ID, version, map[string]interface{}{"name": `"stale"`}, time.Minute)
+	if fields, _ := store.HGetAll(s.productCacheKey(product.ID)); len(fields) != 0 {
+		t.Errorf("stale fill cached %v", fields)
+	}
+}
+
+func TestProductBloomFilterRejectsUnknownIDs(t *testing.T) {
+	_, repos, _ := newTestService(t)
+	products := &countingProducts{ProductRepository: repos.Products}
+	repos.Products = products
+	s := NewSeckillService(config.Load(), repos)
+	existing := createLiveProduct(t, s, 1)
+
+	// 构建前不拦截
+	if _, err := s.GetProduct(999); !errors.Is(err, repository.ErrNotFound) || products.calls.Load() != 1 {
+		t.Fatalf("GetProduct before build = %v after %d queries", err, products.calls.Load())
+	}
+
+	if err := s.BuildProductFilter(); err != nil {
+		t.Fatalf("BuildProductFilter: %v", err)
+	}
+	products.calls.Store(0)
+	for _, id := range []uint{1000, 1001, 1002} {
+		if _, err := s.GetProduct(id); !errors.Is(err, repository.ErrNotFound) {
+			t.Errorf("GetProduct(%d) err = %v; want ErrNotFound", id, err)
+		}
+		if _, err := s.GenerateToken("u1", id); err == nil || err.Error() != "product not found" {
+			t.Errorf("GenerateToken(%d) err = %v; want product not found", id, err)
+		}
+	}
+	if calls := products.calls.Load(); calls != 0 {
+		t.Errorf("unknown IDs reached the database %d times; want 0", calls)
+	}
+
+	if _, err := s.GetProduct(existing.ID); err != nil {
+		t.Errorf("GetProduct existing: %v", err)
+	}
+	created := createLiveProduct(t, s, 2)
+	if _, err := s.GetProduct(created.ID); err != nil {
+		t.Errorf("GetProduct for product created after build: %v", err)
+	}
+
+	// 新实例重建的过滤器不含已删除商品，恢复时重新加入
+	if err := s.DeleteProduct(context.Background(), created.ID, true); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	cfg := config.Load()
+	cfg.Seckill.ProductBloomKey = "seckill:bloom:rebuilt"
+	rebuilt := NewSeckillService(cfg, repos)
+	if err := rebuilt.BuildProductFilter(); err != nil {
+		t.Fatalf("BuildProductFilter: %v", err)
+	}
+	if rebuilt.productMayExist(created.ID) {
+		t.Errorf("deleted product %d still in rebuilt filter", created.ID)
+	}
+	if _, err := rebuilt.RestoreProduct(context.Background(), created.ID); err != nil {
+		t.Fatalf("RestoreProduct: %v", err)
+	}
+	if _, err := rebuilt.GetProduct(created.ID); err != nil {
+		t.Errorf("GetProduct after restore: %v", err)
+	}
+}
+
+func TestProductBloomFilterRebuildsAfterKeyLoss(t *testing.T) {
+	s, _, store := newTestService(t)
+	existing := createLiveProduct(t, s, 1)
+	if err := s.BuildProductFilter(); err != nil {
+		t.Fatalf("BuildProductFilter: %v", err)
+	}
+
+	// 位图键被淘汰后又写入了新商品，位图不完整，已有商品不能被拦截
+	store.Del(s.productFilter.Key())
+	createLiveProduct(t, s, 1)
+	if _, err := s.GetProduct(existing.ID); err != nil {
+		t.Fatalf("GetProduct after bitmap loss: %v", err)
+	}
+
+	// 后台重建完成后恢复拦截
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := s.productFilter.Exists("1"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("product bloom filter was not rebuilt")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !s.productMayExist(existing.ID) || s.productMayExist(999999) {
+		t.Errorf("rebuilt filter: existing = %v, unknown = %v", s.productMayExist(existing.ID), s.productMayExist(999999))
+	}
+}
+
+func TestLiveStockMergesRedisStock(t *testing.T) {
+	s, _, _ := newTestService(t)
+	plenty := createLiveProduct(t, s, 4)
+	single := createLiveProduct(t, s, 1)
+	for _, id := range []uint{plenty.ID, single.ID} {
+		if _, err := buy(t, s, "u1", id); err != nil {
+			t.Fatalf("Seckill %d: %v", id, err)
+		}
+	}
+
+	stocks, err := s.GetLiveStocks([]uint{plenty.ID, 999, single.ID, plenty.ID})
+	if err != nil {
+		t.Fatalf("GetLiveStocks: %v", err)
+	}
+	want := []models.LiveStock{
+		{ProductID: plenty.ID, Remaining: 3, RemainingPercent: 75},
+		{ProductID: single.ID, Remaining: 0, SoldOut: true},
+	}
+	if len(stocks) != len(want) {
+		t.Fatalf("stocks = %+v; want %+v", stocks, want)
+	}
+	for i := range want {
+		if stocks[i] != want[i] {
+			t.Errorf("stock %d = %+v; want %+v", i, stocks[i], want[i])
+		}
+	}
+
+	products, _, err := s.ListProducts(ProductListQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListProducts: %v", err)
+	}
+	for _, product := range products {
+		if product.LiveStock == nil || product.LiveStock.ProductID != product.ID {
+			t.Errorf("product %d live stock = %+v", product.ID, product.LiveStock)
+		}
+	}
+	if products[0].LiveStock.Remaining != 3 || !products[1].LiveStock.SoldOut {
+		t.Errorf("listing live stock = %+v, %+v", products[0].LiveStock, products[1].LiveStock)
+	}
+
+	if _, err := s.GetLiveStocks(make([]uint, MaxLiveStockIDs+1)); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("too many ids err = %v; want ErrInvalidQuery", err)
+	}
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go-seckill/audit"
+	"go-seckill/cache"
+	"go-seckill/models"
+	"go-seckill/repository"
+)
+
+// 库存变动原因
+const (
+	LedgerReasonPreheat      = "preheat"
+	LedgerReasonDecrement    = "decrement"
+	LedgerReasonRollback     = "rollback"
+	LedgerReasonCancelReturn = "cancel_return"
+	LedgerReasonAdjust       = "adjust"
+	LedgerReasonDelete       = "delete"
+	LedgerReasonRestore      = "restore"
+)
+
+// ErrInsufficientStock 调整后库存为负
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// StockLedgerEntry 库存流水，ID为Redis Stream消息ID，按时间单调递增
+type StockLedgerEntry struct {
+	ID            string    `json:"id"`
+	Reason        string    `json:"reason"`
+	Delta         int64     `json:"delta"`
+	Balance       int64     `json:"balance"`
+	CorrelationID string    `json:"correlation_id"`
+	Note          string    `json:"note,omitempty"`
+	At            time.Time `json:"at"`
+}
+
+// stockMoveScript 变动库存并在同一脚本中追加流水，保证库存和流水一致
+// KEYS: 库存键，流水键
+// ARGV: set|incr，数量，原因，关联ID，备注，set时的过期秒数，流水保留条数
+// 返回 {是否成功, 变动后余量}，库存不足时返回当前余量
+var stockMoveScript = cache.RegisterScript("seckill.stock_move", `
+	local old = tonumber(redis.call('get', KEYS[1]) or 0)
+	local amount = tonumber(ARGV[2])
+	local balance
+
+	if ARGV[1] == 'set' then
+		redis.call('set', KEYS[1], amount, 'EX', ARGV[6])
+		balance = amount
+	else
+		if old + amount < 0 then
+			return {0, old}
+		end
+		balance = redis.call('incrby', KEYS[1], amount)
+	end
+
+	redis.call('xadd', KEYS[2], 'MAXLEN', '~', ARGV[7], '*',
+		'reason', ARGV[3], 'delta', balance - old, 'balance', balance,
+		'correlation_id', ARGV[4], 'note', ARGV[5])
+	return {1, balance}
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	old, err := tx.GetInt(keys[0])
+	if err != nil {
+		return nil, err
+	}
+	amount, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance int64
+	if args[0] == "set" {
+		ttl, err := strconv.Atoi(args[5])
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Set(keys[0], amount, time.Duration(ttl)*time.Second); err != nil {
+			return nil, err
+		}
+		balance = amount
+	} else {
+		if old+amount < 0 {
+			return []interface{}{0, old}, nil
+		}
+		if balance, err = tx.IncrBy(keys[0], amount); err != nil {
+			return nil, err
+		}
+	}
+
+	maxLen, err := strconv.ParseInt(args[6], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.XAddMaxLen(keys[1], maxLen, map[string]interface{}{
+		"reason": args[2], "delta": balance - old, "balance": balance,
+		"correlation_id": args[3], "note": args[4],
+	}); err != nil {
+		return nil, err
+	}
+	return []interface{}{1, balance}, nil
+})
+
+// inventoryTag 商品库存相关键的哈希标签，同一库存分组的商品共用一个标签，
+// 未分组的商品各自使用 p<ID>，库存分散到集群的各个槽
+func inventoryTag(product *models.Product) string {
+	if product.InventoryGroup != "" {
+		return "g:" + product.InventoryGroup
+	}
+	return fmt.Sprintf("p%d", product.ID)
+}
+
+func (s *SeckillService) stockKey(product *models.Product) string {
+	return fmt.Sprintf("%s{%s}:%d", s.cfg.Seckill.StockPrefix, inventoryTag(product), product.ID)
+}
+
+func (s *SeckillService) ledgerKey(product *models.Product) string {
+	return fmt.Sprintf("%s{%s}:%d", s.cfg.Seckill.LedgerPrefix, inventoryTag(product), product.ID)
+}
+
+func (s *SeckillService) orderKey(product *models.Product, userID string) string {
+	return fmt.Sprintf("%s{%s}:%s:%d", s.cfg.Seckill.OrderPrefix, inventoryTag(product), userID, product.ID)
+}
+
+// ledgerMaxLen 每个商品保留的库存流水条数，未配置或配置无效时保留10000条
+func (s *SeckillService) ledgerMaxLen() int {
+	if s.cfg.Seckill.LedgerMaxLen <= 0 {
+		return 10000
+	}
+	return s.cfg.Seckill.LedgerMaxLen
+}
+
+// moveStock 执行库存变动并记录流水，mode为set时amount为目标库存，否则为增量
+func (s *SeckillService) moveStock(product *models.Product, mode string, amount int64, reason, correlationID, note string) (int64, error) {
+	var reply struct {
+		OK      bool
+		Balance int64
+	}
+	err := stockMoveScript.Run(
+		[]string{s.stockKey(product), s.ledgerKey(product)},
+		mode, amount, reason, correlationID, note, s.cfg.Seckill.TokenExpire, s.ledgerMaxLen()).Scan(&reply)
+	if err != nil {
+		return 0, err
+	}
+	if !reply.OK {
+		return reply.Balance, ErrInsufficientStock
+	}
+	return reply.Balance, nil
+}
+
+// AdjustStock 管理员调整Redis库存，delta可为负，调整后库存不能为负
+func (s *SeckillService) AdjustStock(ctx context.Context, productID uint, delta int64, note, correlationID string) (int64, error) {
+	product, err := s.products.FindByID(productID)
+	if err != nil {
+		return 0, err
+	}
+	if delta == 0 {
+		return 0, fmt.Errorf("%w: delta must not be zero", ErrInvalidProduct)
+	}
+
+	balance, err := s.moveStock(product, "incr", delta, LedgerReasonAdjust, correlationID, note)
+	if err != nil {
+		return balance, err
+	}
+	audit.Record(ctx, "stock.adjust", "product", productID,
+		map[string]interface{}{"stock": balance - delta},
+		map[string]interface{}{"stock": balance, "note": note, "correlation_id": correlationID})
+	return balance, nil
+}
+
+// stockReturnGuardTTL 取消归还的幂等标记保留时间
+const stockReturnGuardTTL = 7 * 24 * time.Hour
+
+// stockReturnScript 取消订单归还库存：幂等标记、库存增加、流水和清除下单标记在同一脚本中完成，
+// 写入前先校验所有键，校验失败时不做任何修改，幂等标记只在库存归还后存在
+// KEYS: 幂等标记键，库存键 * n，库存流水键 * n，用户下单标记键 * n
+// ARGV: n，订单号，幂等标记过期秒数，归还数量 * n，流水保留条数
+// 返回 {是否归还, 归还的商品数}，已归还过时返回 {0, 0}
+var stockReturnScript = cache.RegisterScript("seckill.stock_return", `
+	local n = tonumber(ARGV[1])
+	if redis.call('exists', KEYS[1]) == 1 then
+		return {0, 0}
+	end
+
+	for i = 1, n do
+		local stock = redis.call('get', KEYS[i + 1])
+		if stock and not string.match(stock, '^-?%d+$') then
+			return redis.error_reply('stock of ' .. KEYS[i + 1] .. ' is not a number')
+		end
+		local kind = redis.call('type', KEYS[n + i + 1]).ok
+		if kind ~= 'none' and kind ~= 'stream' then
+			return redis.error_reply('WRONGTYPE ledger ' .. KEYS[n + i + 1] .. ' is not a stream')
+		end
+	end
+
+	redis.call('set', KEYS[1], 1, 'EX', ARGV[3])
+	for i = 1, n do
+		local balance = redis.call('incrby', KEYS[i + 1], ARGV[i + 3])
+		redis.call('xadd', KEYS[n + i + 1], 'MAXLEN', '~', ARGV[n + 4], '*', 'reason', 'cancel_return', 'delta', tonumber(ARGV[i + 3]),
+			'balance', balance, 'correlation_id', ARGV[2], 'note', '')
+		redis.call('del', KEYS[2 * n + i + 1])
+	end
+	return {1, n}
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if tx.Exists(keys[0]) {
+		return []interface{}{0, 0}, nil
+	}
+
+	quantities := make([]int64, n)
+	for i := 0; i < n; i++ {
+		if quantities[i], err = strconv.ParseInt(args[i+3], 10, 64); err != nil {
+			return nil, err
+		}
+		if _, err := tx.GetInt(keys[i+1]); err != nil {
+			return nil, err
+		}
+		if kind := tx.Type(keys[n+i+1]); kind != "none" && kind != "stream" {
+			return nil, fmt.Errorf("WRONGTYPE ledger %s is not a stream", keys[n+i+1])
+		}
+	}
+
+	ttl, err := strconv.Atoi(args[2])
+	if err != nil {
+		return nil, err
+	}
+	maxLen, err := strconv.ParseInt(args[n+3], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Set(keys[0], 1, time.Duration(ttl)*time.Second); err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		balance, err := tx.IncrBy(keys[i+1], quantities[i])
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.XAddMaxLen(keys[n+i+1], maxLen, map[string]interface{}{
+			"reason": LedgerReasonCancelReturn, "delta": quantities[i], "balance": balance,
+			"correlation_id": args[1], "note": "",
+		}); err != nil {
+			return nil, err
+		}
+		tx.Del(keys[2*n+i+1])
+	}
+	return []interface{}{1, n}, nil
+})
+
+// returnCancelledStock 订单取消后归还库存并清除下单标记，同一订单只归还一次
+// 商品按库存哈希标签分组，每组在一个脚本中归还，失败的分组不留幂等标记，重试时只归还未完成的分组
+func (s *SeckillService) returnCancelledStock(order *models.Order) error {
+	// 升级前的幂等标记不带哈希标签，存在时说明已整单归还过
+	legacyGuard := fmt.Sprintf("%sstock-return:%s", s.cfg.Seckill.LockPrefix, order.OrderNo)
+	if _, err := cache.Get(legacyGuard); err == nil {
+		return nil
+	}
+
+	items := order.Items
+	if len(items) == 0 {
+		items = []models.OrderItem{{ProductID: order.ProductID, Quantity: 1}}
+	}
+	var tags []string
+	groups := make(map[string][]models.OrderItem)
+	products := make(map[uint]*models.Product, len(items))
+	for _, item := range items {
+		// 商品可能已被软删除，库存仍需归还到原来的键上
+		product, err := s.products.FindByID(item.ProductID, repository.WithDeleted())
+		if err != nil {
+			return err
+		}
+		products[item.ProductID] = product
+		tag := inventoryTag(product)
+		if _, ok := groups[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		groups[tag] = append(groups[tag], item)
+	}
+
+	for _, tag := range tags {
+		group := groups[tag]
+		n := len(group)
+		keys := make([]string, 1+3*n)
+		args := []interface{}{n, order.OrderNo, int64(stockReturnGuardTTL / time.Second)}
+		keys[0] = fmt.Sprintf("%s{%s}:stock-return:%s", s.cfg.Seckill.LockPrefix, tag, order.OrderNo)
+		for i, item := range group {
+			product := products[item.ProductID]
+			keys[1+i] = s.stockKey(product)
+			keys[1+n+i] = s.ledgerKey(product)
+			keys[1+2*n+i] = s.orderKey(product, order.UserID)
+			args = append(args, item.Quantity)
+		}
+		args = append(args, s.ledgerMaxLen())
+		if err := stockReturnScript.Run(keys, args...).Err(); err != nil {
+			return fmt.Errorf("return stock of order %s: %w", order.OrderNo, err)
+		}
+	}
+	return nil
+}
+
+// ListStockLedger 按时间倒序分页读取商品库存流水，cursor为上一页最后一条的ID，为空时从最新开始
+func (s *SeckillService) ListStockLedger(productID uint, cursor string, limit int) ([]StockLedgerEntry, string, error) {
+	product, err := s.products.FindByID(productID, repository.FromReplica())
+	if err != nil {
+		return nil, "", err
+	}
+
+	start := "+"
+	count := int64(limit)
+	if cursor != "" {
+		// XREVRANGE 区间包含起点，多取一条并跳过游标本身
+		start = cursor
+		count++
+	}
+	messages, err := cache.XRevRangeN(s.ledgerKey(product), start, "-", count)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]StockLedgerEntry, 0, len(messages))
+	for _, msg := range messages {
+		if msg.ID == cursor || len(entries) == limit {
+			continue
+		}
+		entries = append(entries, parseLedgerEntry(msg.ID, msg.Values))
+	}
+
+	next := ""
+	if len(entries) == limit {
+		next = entries[len(entries)-1].ID
+	}
+	return entries, next, nil
+}
+
+func parseLedgerEntry(id string, values map[string]interface{}) StockLedgerEntry {
+	entry := StockLedgerEntry{ID: id}
+	entry.Reason, _ = values["reason"].(string)
+	entry.CorrelationID, _ = values["correlation_id"].(string)
+	entry.Note, _ = values["note"].(string)
+	if v, ok := values["delta"].(string); ok {
+		entry.Delta, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if v, ok := values["balance"].(string); ok {
+		entry.Balance, _ = strconv.ParseInt(v, 10, 64)
+	}
+	// Stream消息ID格式为 毫秒时间戳-序号
+	var ms int64
+	fmt.Sscanf(id, "%d-", &ms)
+	entry.At = time.UnixMilli(ms)
+	return entry
+}
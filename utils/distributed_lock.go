@@ -1,45 +1,420 @@
 package utils
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"go-seckill/cache"
+
+	"github.com/google/uuid"
 )
 
+// ErrLockLost 锁已过期或被其他客户端持有
+var ErrLockLost = errors.New("distributed lock lost")
+
+// instanceID 当前进程的实例标识，由主机名和进程号组成
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// InstanceID 返回当前进程的实例标识
+func InstanceID() string {
+	return instanceID
+}
+
+// newOwnerToken 生成锁的持有者标识，格式为 实例标识:随机值
+// 随机值保证不同实例、同一实例内的不同持有者不会重复，实例标识便于排查锁被谁持有
+func newOwnerToken() string {
+	return instanceID + ":" + uuid.NewString()
+}
+
+// lockOwnerKey ctx中保存持有者标识的键
+type lockOwnerKey struct{}
+
+// WithLockOwner 返回带有持有者标识的ctx，ctx已带有标识时原样返回
+// 由同一ctx链创建的可重入锁属于同一持有者，嵌套调用可以重复获取同一把锁
+func WithLockOwner(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(lockOwnerKey{}).(string); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, lockOwnerKey{}, newOwnerToken())
+}
+
 // DistributedLock 分布式锁
 type DistributedLock struct {
 	key        string
 	value      string
 	expiration time.Duration
+	reentrant  bool
+
+	// 看门狗状态和可重入锁在本对象上的加锁次数，由mu保护
+	mu           sync.Mutex
+	stopWatchdog chan struct{}
+	watchdogDone chan struct{}
+	lost         chan error
+	holds        int
 }
 
 // NewDistributedLock 创建分布式锁
 func NewDistributedLock(key string, expiration time.Duration) *DistributedLock {
 	return &DistributedLock{
 		key:        key,
-		value:      fmt.Sprintf("%d", time.Now().UnixNano()),
+		value:      newOwnerToken(),
+		expiration: expiration,
+	}
+}
+
+// NewReentrantLock 创建可重入锁，锁保存为哈希，记录持有者的加锁次数
+// 持有者标识取自ctx（见WithLockOwner），ctx不带标识时生成新标识，此时只有同一个锁对象可以重入
+// 每次加锁成功都要对应一次Unlock，次数归零时才释放。同一个键不能混用可重入锁和普通锁
+func NewReentrantLock(ctx context.Context, key string, expiration time.Duration) *DistributedLock {
+	owner, ok := ctx.Value(lockOwnerKey{}).(string)
+	if !ok {
+		owner = newOwnerToken()
+	}
+	return &DistributedLock{
+		key:        key,
+		value:      owner,
 		expiration: expiration,
+		reentrant:  true,
 	}
 }
 
 // Lock 加锁
 func (dl *DistributedLock) Lock() (bool, error) {
-	return cache.SetNX(dl.key, dl.value, dl.expiration)
+	if !dl.reentrant {
+		return cache.SetNX(dl.key, dl.value, dl.expiration)
+	}
+
+	var reply struct{ Locked bool }
+	if err := reentrantLockScript.Run([]string{dl.key}, dl.value, dl.expiration.Milliseconds()).Scan(&reply); err != nil {
+		return false, err
+	}
+	if reply.Locked {
+		dl.mu.Lock()
+		dl.holds++
+		dl.mu.Unlock()
+	}
+	return reply.Locked, nil
 }
 
-// Unlock 解锁
+// unlockScript 只有持有锁的客户端才能解锁，解锁后向释放频道发布通知，唤醒阻塞等待的客户端
+// KEYS: 锁键
+// ARGV: 持有者标识，释放频道
+var unlockScript = cache.RegisterScript("lock.unlock", `
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		redis.call("del", KEYS[1])
+		redis.call("publish", ARGV[2], KEYS[1])
+		return 1
+	else
+		return 0
+	end
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	v, err := tx.Get(keys[0])
+	if errors.Is(err, cache.Nil) || err == nil && v != args[0] {
+		return 0, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tx.Del(keys[0])
+	tx.Publish(args[1], keys[0])
+	return 1, nil
+})
+
+// extendScript 只有持有锁的客户端才能续期
+var extendScript = cache.RegisterScript("lock.extend", `
+	if redis.call("get", KEYS[1]) == ARGV[1] then
+		return redis.call("pexpire", KEYS[1], ARGV[2])
+	else
+		return 0
+	end
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	v, err := tx.Get(keys[0])
+	if errors.Is(err, cache.Nil) || err == nil && v != args[0] {
+		return 0, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ms, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	tx.Expire(keys[0], time.Duration(ms)*time.Millisecond)
+	return 1, nil
+})
+
+// reentrantLockScript 锁不存在或由同一持有者持有时加锁次数加1，并重置有效期
+// KEYS: 锁键
+// ARGV: 持有者标识，有效期（毫秒）
+var reentrantLockScript = cache.RegisterScript("lock.reentrant_lock", `
+	if redis.call("exists", KEYS[1]) == 0 or redis.call("hexists", KEYS[1], ARGV[1]) == 1 then
+		redis.call("hincrby", KEYS[1], ARGV[1], 1)
+		redis.call("pexpire", KEYS[1], ARGV[2])
+		return 1
+	end
+	return 0
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	if tx.Exists(keys[0]) {
+		held, err := tx.HExists(keys[0], args[0])
+		if err != nil || !held {
+			return 0, err
+		}
+	}
+	ms, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.HIncrBy(keys[0], args[0], 1); err != nil {
+		return nil, err
+	}
+	tx.Expire(keys[0], time.Duration(ms)*time.Millisecond)
+	return 1, nil
+})
+
+// reentrantUnlockScript 加锁次数减1，归零时删除锁并发布释放通知，否则重置有效期
+// 返回剩余加锁次数，不是持有者时返回-1
+// KEYS: 锁键
+// ARGV: 持有者标识，有效期（毫秒），释放频道
+var reentrantUnlockScript = cache.RegisterScript("lock.reentrant_unlock", `
+	if redis.call("hexists", KEYS[1], ARGV[1]) == 0 then
+		return -1
+	end
+	local holds = redis.call("hincrby", KEYS[1], ARGV[1], -1)
+	if holds > 0 then
+		redis.call("pexpire", KEYS[1], ARGV[2])
+		return holds
+	end
+	redis.call("del", KEYS[1])
+	redis.call("publish", ARGV[3], KEYS[1])
+	return 0
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	held, err := tx.HExists(keys[0], args[0])
+	if err != nil {
+		return nil, err
+	}
+	if !held {
+		return -1, nil
+	}
+	ms, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	holds, err := tx.HIncrBy(keys[0], args[0], -1)
+	if err != nil {
+		return nil, err
+	}
+	if holds > 0 {
+		tx.Expire(keys[0], time.Duration(ms)*time.Millisecond)
+		return holds, nil
+	}
+	tx.Del(keys[0])
+	tx.Publish(args[2], keys[0])
+	return 0, nil
+})
+
+// reentrantExtendScript 只有持有者才能续期可重入锁
+var reentrantExtendScript = cache.RegisterScript("lock.reentrant_extend", `
+	if redis.call("hexists", KEYS[1], ARGV[1]) == 1 then
+		return redis.call("pexpire", KEYS[1], ARGV[2])
+	end
+	return 0
+`, func(tx *cache.MemoryTx, keys, args []string) (interface{}, error) {
+	held, err := tx.HExists(keys[0], args[0])
+	if err != nil || !held {
+		return 0, err
+	}
+	ms, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	tx.Expire(keys[0], time.Duration(ms)*time.Millisecond)
+	return 1, nil
+})
+
+// Unlock 解锁，同时停止看门狗
+// 可重入锁每次只减少一次加锁次数，本对象的最后一次Unlock才停止看门狗；
+// 本对象没有未释放的加锁时直接返回，避免减掉共享同一持有者标识的其他锁对象的次数；
+// 锁已过期或被其他客户端持有时返回ErrLockLost
 func (dl *DistributedLock) Unlock() error {
-	// 使用Lua脚本确保原子性：只有持有锁的客户端才能解锁
-	script := `
-		if redis.call("get", KEYS[1]) == ARGV[1] then
-			return redis.call("del", KEYS[1])
-		else
-			return 0
-		end
-	`
-	_, err := cache.Eval(script, []string{dl.key}, dl.value)
-	return err
+	if !dl.reentrant {
+		dl.stopWatchdogAndWait()
+		var reply struct{ Released bool }
+		if err := unlockScript.Run([]string{dl.key}, dl.value, releaseChannel(dl.key)).Scan(&reply); err != nil {
+			return err
+		}
+		if !reply.Released {
+			return ErrLockLost
+		}
+		return nil
+	}
+
+	dl.mu.Lock()
+	if dl.holds == 0 {
+		dl.mu.Unlock()
+		return nil
+	}
+	dl.holds--
+	last := dl.holds == 0
+	dl.mu.Unlock()
+	if last {
+		dl.stopWatchdogAndWait()
+	}
+
+	var reply struct{ Holds int64 }
+	err := reentrantUnlockScript.Run([]string{dl.key}, dl.value, dl.expiration.Milliseconds(), releaseChannel(dl.key)).Scan(&reply)
+	if err != nil {
+		return err
+	}
+	if reply.Holds < 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// Extend 将锁的剩余有效期重置为expiration，锁已不属于当前持有者时返回false
+func (dl *DistributedLock) Extend() (bool, error) {
+	script := extendScript
+	if dl.reentrant {
+		script = reentrantExtendScript
+	}
+	var reply struct{ Extended bool }
+	err := script.Run([]string{dl.key}, dl.value, dl.expiration.Milliseconds()).Scan(&reply)
+	return reply.Extended, err
+}
+
+// StartWatchdog 加锁成功后启动看门狗，持有锁期间每隔expiration/3续期一次
+// 看门狗在Unlock、ctx结束或失去锁后停止。失去锁时返回的通道收到ErrLockLost，看门狗停止后通道关闭；
+// 续期请求出错时继续重试，直到锁按上次成功续期的时间已经过期才视为失去锁
+// 看门狗运行期间重复调用返回同一个通道
+func (dl *DistributedLock) StartWatchdog(ctx context.Context) <-chan error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if dl.stopWatchdog != nil {
+		return dl.lost
+	}
+
+	stop, done, lost := make(chan struct{}), make(chan struct{}), make(chan error, 1)
+	dl.stopWatchdog, dl.watchdogDone, dl.lost = stop, done, lost
+
+	go func() {
+		defer close(done)
+		defer close(lost)
+
+		ticker := time.NewTicker(dl.expiration / 3)
+		defer ticker.Stop()
+		deadline := time.Now().Add(dl.expiration)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			extended, err := dl.Extend()
+			switch {
+			case err == nil && extended:
+				deadline = time.Now().Add(dl.expiration)
+			case err == nil:
+				lost <- ErrLockLost
+				return
+			case !time.Now().Before(deadline):
+				lost <- fmt.Errorf("%w: %v", ErrLockLost, err)
+				return
+			default:
+				log.Printf("Failed to extend lock %s: %v", dl.key, err)
+			}
+		}
+	}()
+	return lost
+}
+
+// stopWatchdogAndWait 停止看门狗并等待其退出，避免解锁后又被续期
+func (dl *DistributedLock) stopWatchdogAndWait() {
+	dl.mu.Lock()
+	stop, done := dl.stopWatchdog, dl.watchdogDone
+	dl.stopWatchdog, dl.watchdogDone, dl.lost = nil, nil, nil
+	dl.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// 阻塞加锁时的重试间隔，收不到释放通知（如持有者崩溃后锁自然过期）时按指数退避重试
+const (
+	lockMinBackoff = 10 * time.Millisecond
+	lockMaxBackoff = 500 * time.Millisecond
+)
+
+// releaseChannel 锁释放通知的频道
+func releaseChannel(key string) string {
+	return "lock:released:" + key
+}
+
+// LockContext 阻塞直到加锁成功或ctx结束，ctx结束时返回ctx.Err()
+// 锁被占用时订阅释放频道，持有者解锁后立即被唤醒重试；同时按带抖动的指数退避定期重试，
+// 覆盖锁过期和通知丢失的情况。每个等待中的调用占用一个订阅连接
+func (dl *DistributedLock) LockContext(ctx context.Context) error {
+	locked, err := dl.Lock()
+	if err != nil || locked {
+		return err
+	}
+
+	sub := cache.Subscribe(releaseChannel(dl.key))
+	defer sub.Close()
+	var released <-chan *cache.Message
+	if err := sub.Wait(ctx); err == nil {
+		released = sub.Channel()
+	} else if ctx.Err() != nil {
+		return ctx.Err()
+	} else {
+		// 订阅失败时只靠退避重试
+		log.Printf("Failed to subscribe to release of lock %s: %v", dl.key, err)
+	}
+
+	backoff := lockMinBackoff
+	for {
+		// 订阅生效后先重试一次，避免订阅前发生的解锁通知丢失
+		locked, err := dl.Lock()
+		if err != nil || locked {
+			return err
+		}
+
+		// 在 [backoff/2, backoff) 内随机等待，避免多个等待者同时重试
+		timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2))))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case _, ok := <-released:
+			timer.Stop()
+			if !ok {
+				released = nil
+			}
+		case <-timer.C:
+			if backoff *= 2; backoff > lockMaxBackoff {
+				backoff = lockMaxBackoff
+			}
+		}
+	}
 }
 
 // TryLockWithRetry 尝试加锁，带重试机制
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go-seckill/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestStore(t *testing.T) *cache.MemoryStore {
+	t.Helper()
+	store := cache.NewMemoryStore()
+	cache.SetStore(store)
+	return store
+}
+
+// TestLockScriptsMatchLua 续期和解锁脚本在miniredis和内存存储上行为一致
+func TestLockScriptsMatchLua(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	for name, store := range map[string]cache.Store{"redis": cache.NewRedisStore(client), "memory": cache.NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			cache.SetStore(store)
+			lock := NewDistributedLock("lock:script", 10*time.Second)
+			if extended, err := lock.Extend(); err != nil || extended {
+				t.Errorf("Extend before Lock = %v, %v; want false", extended, err)
+			}
+			lock.Lock()
+			store.Expire("lock:script", time.Second)
+			if extended, err := lock.Extend(); err != nil || !extended {
+				t.Errorf("Extend = %v, %v; want true", extended, err)
+			}
+			if ttl, _ := store.TTL("lock:script"); ttl != 10*time.Second {
+				t.Errorf("ttl after Extend = %v; want 10s", ttl)
+			}
+
+			other := NewDistributedLock("lock:script", 10*time.Second)
+			if extended, _ := other.Extend(); extended {
+				t.Error("non-owner extended the lock")
+			}
+			if err := other.Unlock(); !errors.Is(err, ErrLockLost) {
+				t.Errorf("non-owner Unlock = %v; want ErrLockLost", err)
+			}
+			if _, err := store.Get("lock:script"); err != nil {
+				t.Errorf("non-owner released the lock: %v", err)
+			}
+			sub := store.Subscribe(releaseChannel("lock:script"))
+			defer sub.Close()
+			if err := sub.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait: %v", err)
+			}
+			if err := lock.Unlock(); err != nil {
+				t.Errorf("owner Unlock: %v", err)
+			}
+			if _, err := store.Get("lock:script"); !errors.Is(err, cache.Nil) {
+				t.Errorf("owner Unlock left the lock: %v", err)
+			}
+			select {
+			case msg := <-sub.Channel():
+				if msg.Payload != "lock:script" {
+					t.Errorf("release notification = %+v", msg)
+				}
+			case <-time.After(time.Second):
+				t.Error("Unlock did not publish a release notification")
+			}
+		})
+	}
+}
+
+// TestReentrantLockScriptsMatchLua 可重入锁的加锁、续期和解锁脚本在miniredis和内存存储上行为一致
+func TestReentrantLockScriptsMatchLua(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	for name, store := range map[string]cache.Store{"redis": cache.NewRedisStore(client), "memory": cache.NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) {
+			cache.SetStore(store)
+			ctx := WithLockOwner(context.Background())
+			outer := NewReentrantLock(ctx, "lock:reentrant", 10*time.Second)
+			inner := NewReentrantLock(ctx, "lock:reentrant", 10*time.Second)
+			other := NewReentrantLock(context.Background(), "lock:reentrant", 10*time.Second)
+
+			if extended, err := outer.Extend(); err != nil || extended {
+				t.Errorf("Extend before Lock = %v, %v; want false", extended, err)
+			}
+			for _, lock := range []*DistributedLock{outer, inner, outer} {
+				if locked, err := lock.Lock(); err != nil || !locked {
+					t.Fatalf("owner Lock = %v, %v; want true", locked, err)
+				}
+			}
+			if holds, _ := store.HGet("lock:reentrant", outer.value); holds != "3" {
+				t.Errorf("holds = %q; want 3", holds)
+			}
+			if locked, err := other.Lock(); err != nil || locked {
+				t.Errorf("other owner Lock = %v, %v; want false", locked, err)
+			}
+			if extended, _ := other.Extend(); extended {
+				t.Error("non-owner extended the lock")
+			}
+			other.Unlock()
+
+			store.Expire("lock:reentrant", time.Second)
+			if extended, err := inner.Extend(); err != nil || !extended {
+				t.Errorf("Extend = %v, %v; want true", extended, err)
+			}
+			if ttl, _ := store.TTL("lock:reentrant"); ttl != 10*time.Second {
+				t.Errorf("ttl after Extend = %v; want 10s", ttl)
+			}
+
+			sub := store.Subscribe(releaseChannel("lock:reentrant"))
+			defer sub.Close()
+			if err := sub.Wait(context.Background()); err != nil {
+				t.Fatalf("Wait: %v", err)
+			}
+			inner.Unlock()
+			outer.Unlock()
+			if holds, _ := store.HGet("lock:reentrant", outer.value); holds != "1" {
+				t.Errorf("holds after two Unlocks = %q; want 1", holds)
+			}
+			outer.Unlock()
+			if _, err := store.HGet("lock:reentrant", outer.value); !errors.Is(err, cache.Nil) {
+				t.Errorf("last Unlock left the lock: %v", err)
+			}
+			select {
+			case msg := <-sub.Channel():
+				if msg.Payload != "lock:reentrant" {
+					t.Errorf("release notification = %+v", msg)
+				}
+			case <-time.After(time.Second):
+				t.Error("last Unlock did not publish a release notification")
+			}
+
+			// 本对象没有未释放的加锁时Unlock直接返回，不减少共享持有者标识的其他锁对象的次数
+			if locked, err := inner.Lock(); err != nil || !locked {
+				t.Fatalf("inner Lock = %v, %v; want true", locked, err)
+			}
+			if err := outer.Unlock(); err != nil {
+				t.Errorf("extra Unlock = %v; want nil", err)
+			}
+			if holds, _ := store.HGet("lock:reentrant", inner.value); holds != "1" {
+				t.Errorf("holds after extra Unlock = %q; want 1", holds)
+			}
+			// 锁过期后Unlock返回ErrLockLost
+			store.Del("lock:reentrant")
+			if err := inner.Unlock(); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Unlock after expiry = %v; want ErrLockLost", err)
+			}
+
+			// 同一个键不能混用可重入锁和普通锁
+			store.Set("lock:plain", "owner", time.Minute)
+			if _, err := NewReentrantLock(ctx, "lock:plain", time.Minute).Lock(); err == nil {
+				t.Error("reentrant Lock on a plain lock key should fail")
+			}
+		})
+	}
+}
+
+func TestOwnerTokens(t *testing.T) {
+	a, b := NewDistributedLock("lock:a", time.Second), NewDistributedLock("lock:a", time.Second)
+	if a.value == b.value {
+		t.Errorf("two locks share owner token %q", a.value)
+	}
+	if !strings.HasPrefix(a.value, InstanceID()+":") {
+		t.Errorf("owner token %q does not start with instance ID %q", a.value, InstanceID())
+	}
+
+	ctx := WithLockOwner(context.Background())
+	if WithLockOwner(ctx) != ctx {
+		t.Error("WithLockOwner replaced an existing owner")
+	}
+	if x, y := NewReentrantLock(ctx, "lock:a", time.Second), NewReentrantLock(ctx, "lock:b", time.Second); x.value != y.value {
+		t.Errorf("reentrant locks from the same ctx have owners %q and %q", x.value, y.value)
+	}
+	if x, y := NewReentrantLock(context.Background(), "lock:a", time.Second), NewReentrantLock(context.Background(), "lock:a", time.Second); x.value == y.value {
+		t.Error("reentrant locks without an owner in ctx share a token")
+	}
+}
+
+func TestReentrantWatchdogRunsUntilLastUnlock(t *testing.T) {
+	store := newTestStore(t)
+	lock := NewReentrantLock(context.Background(), "lock:nested", 300*time.Millisecond)
+	lock.Lock()
+	lost := lock.StartWatchdog(context.Background())
+	lock.Lock()
+
+	// 内层解锁后仍被外层持有，看门狗继续续期
+	lock.Unlock()
+	time.Sleep(time.Second)
+	if holds, err := store.HGet("lock:nested", lock.value); err != nil || holds != "1" {
+		t.Fatalf("holds = %q, %v; want 1", holds, err)
+	}
+
+	lock.Unlock()
+	if err, ok := <-lost; ok {
+		t.Errorf("lost channel received %v after Unlock; want closed", err)
+	}
+	if _, err := store.HGet("lock:nested", lock.value); !errors.Is(err, cache.Nil) {
+		t.Errorf("lock still present after last Unlock: %v", err)
+	}
+}
+
+func TestWatchdogExtendsLeaseUntilUnlock(t *testing.T) {
+	store := newTestStore(t)
+	lock := NewDistributedLock("lock:watchdog", 300*time.Millisecond)
+	if locked, err := lock.Lock(); err != nil || !locked {
+		t.Fatalf("Lock = %v, %v", locked, err)
+	}
+	lost := lock.StartWatchdog(context.Background())
+
+	// 持有时间远超有效期，锁仍然有效
+	time.Sleep(time.Second)
+	if v, err := store.Get("lock:watchdog"); err != nil || v != lock.value {
+		t.Fatalf("lock value = %q, %v; want held by owner", v, err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err, ok := <-lost; ok {
+		t.Errorf("lost channel received %v after Unlock; want closed", err)
+	}
+	if _, err := store.Get("lock:watchdog"); !errors.Is(err, cache.Nil) {
+		t.Errorf("lock still present after Unlock: %v", err)
+	}
+}
+
+func TestWatchdogReportsLostLock(t *testing.T) {
+	store := newTestStore(t)
+	lock := NewDistributedLock("lock:lost", 150*time.Millisecond)
+	if locked, _ := lock.Lock(); !locked {
+		t.Fatal("Lock failed")
+	}
+	lost := lock.StartWatchdog(context.Background())
+
+	// 模拟锁过期后被其他客户端获取
+	store.Set("lock:lost", "other", time.Minute)
+	select {
+	case err := <-lost:
+		if !errors.Is(err, ErrLockLost) {
+			t.Errorf("lost err = %v; want ErrLockLost", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lost lock not reported")
+	}
+
+	// 解锁不会删除其他客户端的锁
+	lock.Unlock()
+	if v, _ := store.Get("lock:lost"); v != "other" {
+		t.Errorf("lock value = %q; want other", v)
+	}
+}
+
+func TestWatchdogStopsOnContextCancel(t *testing.T) {
+	store := newTestStore(t)
+	lock := NewDistributedLock("lock:ctx", 150*time.Millisecond)
+	if locked, _ := lock.Lock(); !locked {
+		t.Fatal("Lock failed")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	lost := lock.StartWatchdog(ctx)
+	cancel()
+
+	if err, ok := <-lost; ok {
+		t.Errorf("lost channel received %v after cancel; want closed", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if _, err := store.Get("lock:ctx"); !errors.Is(err, cache.Nil) {
+		t.Errorf("lock renewed after watchdog stopped: %v", err)
+	}
+}
+
+func TestLockContextWakesOnUnlock(t *testing.T) {
+	newTestStore(t)
+	holder := NewDistributedLock("lock:wait", time.Minute)
+	if locked, _ := holder.Lock(); !locked {
+		t.Fatal("Lock failed")
+	}
+
+	acquired := make(chan time.Time, 1)
+	waiter := NewDistributedLock("lock:wait", time.Minute)
+	go func() {
+		if err := waiter.LockContext(context.Background()); err != nil {
+			t.Errorf("LockContext: %v", err)
+		}
+		acquired <- time.Now()
+	}()
+
+	// 等待足够久，让退避间隔增长到远大于通知唤醒的延迟
+	time.Sleep(400 * time.Millisecond)
+	select {
+	case <-acquired:
+		t.Fatal("waiter acquired a held lock")
+	default:
+	}
+	released := time.Now()
+	holder.Unlock()
+
+	select {
+	case at := <-acquired:
+		if wait := at.Sub(released); wait > 50*time.Millisecond {
+			t.Errorf("waiter woke %v after Unlock; want pub/sub wakeup", wait)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter not woken after Unlock")
+	}
+	waiter.Unlock()
+}
+
+func TestLockContextHonoursContext(t *testing.T) {
+	newTestStore(t)
+	holder := NewDistributedLock("lock:busy", time.Minute)
+	holder.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := NewDistributedLock("lock:busy", time.Minute).LockContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LockContext err = %v; want DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+		t.Errorf("LockContext returned after %v; want about 100ms", elapsed)
+	}
+}
+
+func TestLockContextRetriesAfterExpiry(t *testing.T) {
+	newTestStore(t)
+	// 持有者崩溃，锁过期时没有释放通知，只能靠退避重试获取
+	NewDistributedLock("lock:expiring", 100*time.Millisecond).Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := NewDistributedLock("lock:expiring", time.Minute).LockContext(ctx); err != nil {
+		t.Errorf("LockContext after expiry: %v", err)
+	}
+}
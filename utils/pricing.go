@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DutchAuctionPrice 计算降价拍在指定时刻的价格及下一次降价时间
+// 价格从startPrice开始，每interval下降step，不低于floor；已到底价或活动结束后nextDrop为nil
+func DutchAuctionPrice(startTime, endTime time.Time, startPrice, floor, step float64, interval time.Duration, now time.Time) (float64, *time.Time) {
+	start := decimal.NewFromFloat(startPrice)
+	floorPrice := decimal.NewFromFloat(floor)
+	if interval <= 0 || step <= 0 || !start.GreaterThan(floorPrice) {
+		return startPrice, nil
+	}
+
+	if now.After(endTime) {
+		now = endTime
+	}
+
+	var steps int64
+	if now.After(startTime) {
+		steps = int64(now.Sub(startTime) / interval)
+	}
+
+	price := start.Sub(decimal.NewFromFloat(step).Mul(decimal.NewFromInt(steps)))
+	if !price.GreaterThan(floorPrice) {
+		f, _ := floorPrice.Round(2).Float64()
+		return f, nil
+	}
+
+	current, _ := price.Round(2).Float64()
+	next := startTime.Add(time.Duration(steps+1) * interval)
+	if !next.Before(endTime) {
+		return current, nil
+	}
+	return current, &next
+}
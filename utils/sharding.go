@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+// MaxOrderShards 订单号中分片号占两位，最多支持100个分片
+const MaxOrderShards = 100
+
+// OrderShard 按用户ID哈希计算订单分片号
+func OrderShard(userID string, shards int) int {
+	if shards <= 1 {
+		return 0
+	}
+	return int(crc32.ChecksumIEEE([]byte(userID)) % uint32(shards))
+}
+
+// 订单号格式为 ORS{分片号两位}{分片数三位}{时间戳}{随机串}，ORS 是带分片信息的版本标记；
+// 旧版本的订单号 ORD{时间戳}{随机串} 不含分片信息，这类订单写在未分片的 orders 表中
+const (
+	orderNoPrefix       = "ORS"
+	legacyOrderNoPrefix = "ORD"
+)
+
+// ParseOrderShard 从订单号中解析生成订单时的分片号和分片数，旧格式或无法解析的订单号返回false
+func ParseOrderShard(orderNo string) (shard, shards int, ok bool) {
+	if !strings.HasPrefix(orderNo, orderNoPrefix) || len(orderNo) < 8 {
+		return 0, 0, false
+	}
+	shard, err1 := strconv.Atoi(orderNo[3:5])
+	shards, err2 := strconv.Atoi(orderNo[5:8])
+	if err1 != nil || err2 != nil || shards < 1 || shard >= shards {
+		return 0, 0, false
+	}
+	return shard, shards, true
+}
+
+// IsLegacyOrderNo 判断是否为不含分片信息的旧格式订单号
+func IsLegacyOrderNo(orderNo string) bool {
+	return strings.HasPrefix(orderNo, legacyOrderNoPrefix)
+}
+
+// OrderTable 返回分片对应的订单表名，未分片时为 orders
+func OrderTable(shard, shards int) string {
+	if shards <= 1 {
+		return "orders"
+	}
+	return fmt.Sprintf("orders_%02d", shard)
+}
+
+// OrderItemTable 返回分片对应的订单明细表名，明细与订单位于同一分片
+func OrderItemTable(shard, shards int) string {
+	if shards <= 1 {
+		return "order_items"
+	}
+	return fmt.Sprintf("order_items_%02d", shard)
+}
@@ -7,9 +7,12 @@ import (
 	"github.com/google/uuid"
 )
 
-// GenerateOrderNo 生成订单号
-func GenerateOrderNo() string {
-	return fmt.Sprintf("ORD%d%s", time.Now().Unix(), uuid.New().String()[:8])
+// GenerateOrderNo 生成订单号，订单号中编码了分片号和分片数，便于按订单号直接路由
+func GenerateOrderNo(shard, shards int) string {
+	if shards < 1 {
+		shards = 1
+	}
+	return fmt.Sprintf("%s%02d%03d%d%s", orderNoPrefix, shard, shards, time.Now().Unix(), uuid.New().String()[:8])
 }
 
 // IsSeckillTime 判断是否在秒杀时间内